@@ -0,0 +1,159 @@
+package weblib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// fingerprintSize is the number of hex characters of the content hash included in a fingerprinted file name.
+const fingerprintSize = 8
+
+// immutableCacheControl is the Cache-Control header of fingerprinted files, which never change at the same URL.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// defaultAssets are the assets that AssetURL looks names up in.
+var defaultAssets atomic.Pointer[Assets]
+
+// Assets serves static files at fingerprinted URLs that include a hash of their content, such as app.3f9a1c4d.js for
+// app.js, so that they can be cached by browsers indefinitely and are fetched again only when they change.
+type Assets struct {
+	prefix  string
+	urls    map[string]string
+	files   map[string]string
+	handler http.Handler
+}
+
+// NewAssets returns Assets that fingerprint the files in fsys, which are served under the given prefix. The prefix
+// should match the route used in your handler.
+//
+// Every file is read and hashed once when called, so files added or changed afterwards are not fingerprinted.
+// Precompressed siblings, such as app.js.br, are not fingerprinted themselves but are served in place of the file
+// they compress as with ServeFiles.
+func NewAssets(prefix string, fsys fs.FS) (*Assets, error) {
+	a := &Assets{
+		prefix: prefix,
+		urls:   make(map[string]string),
+		files:  make(map[string]string),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isPrecompressed(name) {
+			return err
+		}
+
+		sum, err := hashFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		fingerprinted := fingerprint(name, sum)
+		a.urls[name] = path.Join("/", prefix, fingerprinted)
+		a.files[fingerprinted] = name
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint assets: %w", err)
+	}
+
+	a.handler = http.StripPrefix(prefix, fileServer(http.FS(fsys), ServeOptions{}))
+
+	return a, nil
+}
+
+// NewAssetsDir returns Assets that fingerprint the files in the given root directory, as served by ServeFiles.
+func NewAssetsDir(prefix, root string) (*Assets, error) {
+	return NewAssets(prefix, os.DirFS(root))
+}
+
+// SetAssets sets the assets that AssetURL looks names up in.
+func SetAssets(a *Assets) {
+	defaultAssets.Store(a)
+}
+
+// AssetURL returns the fingerprinted URL of the file with the given name in the assets set by SetAssets, such as
+// "/static/app.3f9a1c4d.js" for "app.js". If no assets are set, the name is returned unchanged.
+//
+// It is intended for use in templ components, such as <script src={ weblib.AssetURL("app.js") }></script>.
+func AssetURL(name string) string {
+	a := defaultAssets.Load()
+	if a == nil {
+		return name
+	}
+
+	return a.URL(name)
+}
+
+// URL returns the fingerprinted URL of the file with the given name, relative to the root of the assets. If the file
+// was not fingerprinted, its URL under the prefix is returned instead.
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if u, ok := a.urls[name]; ok {
+		return u
+	}
+
+	return path.Join("/", a.prefix, name)
+}
+
+// ServeHTTP serves the file at the requested path. Fingerprinted paths are served with a Cache-Control header that lets
+// them be cached indefinitely, while other paths are served as they are. Directories are never listed.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel, ok := strings.CutPrefix(r.URL.Path, a.prefix)
+	if ok {
+		if name, ok := a.files[strings.TrimPrefix(rel, "/")]; ok {
+			w.Header().Set("Cache-Control", immutableCacheControl)
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = path.Join("/", a.prefix, name)
+			r2.URL.RawPath = ""
+			r = r2
+		}
+	}
+
+	a.handler.ServeHTTP(w, r)
+}
+
+// fingerprint returns the name with the first characters of the hex encoded hash inserted before its extension.
+func fingerprint(name string, sum []byte) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum)[:fingerprintSize] + ext
+}
+
+// hashFile returns the sha256 hash of the content of the named file.
+func hashFile(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// isPrecompressed returns true if the name has the extension of a precompressed file.
+func isPrecompressed(name string) bool {
+	for _, pc := range precompressedExtensions {
+		if strings.HasSuffix(name, pc.ext) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package weblib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestAssets(t *testing.T) *Assets {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		"app.js":        {Data: []byte("console.log('hello')")},
+		"app.js.br":     {Data: []byte("brotli")},
+		"css/style.css": {Data: []byte("body {}")},
+	}
+
+	a, err := NewAssets("/static/", fsys)
+	if err != nil {
+		t.Fatalf("failed to create assets: %v", err)
+	}
+
+	return a
+}
+
+func TestAssets_URL(t *testing.T) {
+	a := newTestAssets(t)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "app.js", want: `^/static/app\.[0-9a-f]{8}\.js$`},
+		{name: "/css/style.css", want: `^/static/css/style\.[0-9a-f]{8}\.css$`},
+		{name: "missing.js", want: `^/static/missing\.js$`},
+		{name: "app.js.br", want: `^/static/app\.js\.br$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.URL(tt.name); !regexp.MustCompile(tt.want).MatchString(got) {
+				t.Errorf("expected URL matching %s, got %q", tt.want, got)
+			}
+		})
+	}
+
+	a2, err := NewAssets("/static/", fstest.MapFS{"app.js": {Data: []byte("console.log('changed')")}})
+	if err != nil {
+		t.Fatalf("failed to create assets: %v", err)
+	}
+
+	if a.URL("app.js") == a2.URL("app.js") {
+		t.Error("expected the URL to change with the content")
+	}
+}
+
+func TestAssetURL(t *testing.T) {
+	defer SetAssets(nil)
+
+	if got := AssetURL("app.js"); got != "app.js" {
+		t.Errorf("expected the name unchanged without assets, got %q", got)
+	}
+
+	a := newTestAssets(t)
+	SetAssets(a)
+
+	if got := AssetURL("app.js"); got != a.URL("app.js") {
+		t.Errorf("expected %q, got %q", a.URL("app.js"), got)
+	}
+}
+
+func TestAssets_ServeHTTP(t *testing.T) {
+	a := newTestAssets(t)
+
+	tests := []struct {
+		name           string
+		url            string
+		acceptEncoding string
+		wantCode       int
+		wantBody       string
+		wantImmutable  bool
+	}{
+		{
+			name:          "fingerprinted",
+			url:           a.URL("css/style.css"),
+			wantCode:      http.StatusOK,
+			wantBody:      "body {}",
+			wantImmutable: true,
+		},
+		{
+			name:           "fingerprinted precompressed",
+			url:            a.URL("app.js"),
+			acceptEncoding: "br",
+			wantCode:       http.StatusOK,
+			wantBody:       "brotli",
+			wantImmutable:  true,
+		},
+		{
+			name:     "original name",
+			url:      "/static/css/style.css",
+			wantCode: http.StatusOK,
+			wantBody: "body {}",
+		},
+		{
+			name:     "stale fingerprint",
+			url:      "/static/css/style.00000000.css",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "directory",
+			url:      "/static/css/",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+
+			if immutable := rec.Header().Get("Cache-Control") == immutableCacheControl; immutable != tt.wantImmutable {
+				t.Errorf("expected immutable caching to be %v", tt.wantImmutable)
+			}
+		})
+	}
+}
@@ -1,34 +1,486 @@
 package weblib
 
 import (
+	"container/list"
+	"context"
+	"fmt"
+	"hash/maphash"
+	"log"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type cacheItem struct {
+// ExpiryMode determines how the expiry of a cache entry is calculated.
+type ExpiryMode int
+
+const (
+	// SlidingExpiry expires an entry once it has not been accessed for its ttl. Every successful Get extends the
+	// expiry of the entry by its ttl.
+	SlidingExpiry ExpiryMode = iota
+
+	// AbsoluteExpiry expires an entry once its ttl has elapsed since it was put, regardless of access.
+	AbsoluteExpiry
+)
+
+// CacheOption configures optional behaviour of a cache on creation.
+type CacheOption func(*cacheConfig)
+
+type cacheConfig struct {
+	mode       ExpiryMode
+	maxEntries int
+	policy     EvictionPolicy
+	errorTTL   time.Duration
+	shards     int
+	codec      Codec
+	snapshot   string
+}
+
+// WithExpiryMode sets the default expiry mode of a cache, used by Put and PutWithTTL. The default is SlidingExpiry.
+func WithExpiryMode(mode ExpiryMode) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.mode = mode
+	}
+}
+
+// WithMaxEntries bounds the cache to hold at most n entries, evicting entries according to the eviction policy of the
+// cache once full. A value of n less than 1 leaves the number of entries unbounded.
+func WithMaxEntries(n int) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.maxEntries = n
+	}
+}
+
+// WithEvictionPolicy sets the policy used to choose which entries to evict from a bounded cache once full.
+// The default is LRU.
+func WithEvictionPolicy(policy EvictionPolicy) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.policy = policy
+	}
+}
+
+// WithLoadErrorTTL caches errors returned by the loader given to GetOrLoad for the given ttl, returning the cached
+// error to subsequent calls for the same key without calling the loader. By default, loader errors are not cached.
+func WithLoadErrorTTL(ttl time.Duration) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.errorTTL = ttl
+	}
+}
+
+// WithShards splits the cache into n independently locked shards, rounded up to a power of two, so that operations
+// on keys in different shards do not contend with each other and the cleaner only locks one shard at a time.
+//
+// Unbounded caches default to four shards per GOMAXPROCS. Bounded caches default to a single shard so that eviction
+// follows the eviction policy exactly. When a bounded cache has more than one shard, its limits are split evenly
+// between the shards and the eviction policy is applied per shard.
+func WithShards(n int) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.shards = n
+	}
+}
+
+// WithCodec sets the codec used by Snapshot and Restore to encode the entries of the cache. The default is GobCodec.
+func WithCodec(codec Codec) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.codec = codec
+	}
+}
+
+// WithSnapshotFile restores the cache from the snapshot file at path on creation, if it exists, and saves a snapshot
+// of the cache to it on Close. Failures to restore or save the snapshot are logged.
+func WithSnapshotFile(path string) CacheOption {
+	return func(cfg *cacheConfig) {
+		cfg.snapshot = path
+	}
+}
+
+// EvictReason describes why an entry was removed from a cache.
+type EvictReason int
+
+const (
+	// EvictExpired is the reason given for an entry removed because its ttl elapsed.
+	EvictExpired EvictReason = iota
+
+	// EvictCapacity is the reason given for an entry removed to keep a bounded cache within its limits.
+	EvictCapacity
+
+	// EvictDeleted is the reason given for an entry removed explicitly by Delete.
+	EvictDeleted
+
+	// EvictReplaced is the reason given for an entry removed because a new value was put with the same key.
+	EvictReplaced
+
+	// EvictClosed is the reason given for an entry removed because the cache was closed.
+	EvictClosed
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictCapacity:
+		return "capacity"
+	case EvictDeleted:
+		return "deleted"
+	case EvictReplaced:
+		return "replaced"
+	case EvictClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("EvictReason(%d)", int(r))
+	}
+}
+
+// closedChan is a closed channel returned when there is nothing to wait for.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// callbackGroup counts the running eviction callbacks of a cache so that closing can wait for them. Unlike a
+// sync.WaitGroup, callbacks may start while another goroutine is waiting.
+type callbackGroup struct {
+	mu      sync.Mutex
+	running int
+	idle    chan struct{}
+}
+
+// add records the start of a callback.
+func (g *callbackGroup) add() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.running++
+}
+
+// done records the end of a callback.
+func (g *callbackGroup) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.running--
+	if g.running == 0 {
+		close(g.idle)
+	}
+}
+
+// wait returns a channel that is closed once no callbacks are running.
+func (g *callbackGroup) wait() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.running == 0 {
+		return closedChan
+	}
+
+	return g.idle
+}
+
+// eviction records an entry removed from a cache so the eviction callback can be called once the lock is released.
+type eviction[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+type cacheItem[K comparable, V any] struct {
+	key        K
+	created    time.Time
 	lastAccess time.Time
-	value      any
+	expiresAt  time.Time
+	ttl        time.Duration
+	mode       ExpiryMode
+	value      V
+	size       int64
+	hits       uint64
+	elem       *list.Element
+	index      int
+	tags       []string
+}
+
+// expired returns true if the item has expired at the given time.
+func (i *cacheItem[K, V]) expired(now time.Time) bool {
+	return now.After(i.expiresAt)
+}
+
+// touch records an access of the item at the given time, extending its expiry if it is sliding.
+func (i *cacheItem[K, V]) touch(now time.Time) {
+	i.lastAccess = now
+	i.hits++
+	if i.mode == SlidingExpiry {
+		i.expiresAt = now.Add(i.ttl)
+	}
 }
 
+// cacheShard is an independently locked partition of the keys of a cache.
+type cacheShard[K comparable, V any] struct {
+	mu         sync.Mutex
+	data       map[K]*cacheItem[K, V]
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	evictor    evictor[K, V]
+	loads      map[K]*loadCall[V]
+	failures   map[K]loadFailure
+	tags       map[string]map[K]struct{}
+
+	// closed is set once the shard has been drained by Close, after which values put are not stored
+	closed bool
+}
+
+// TypedCache is a type safe in memory cache that maps keys of type K to values of type V.
+type TypedCache[K comparable, V any] struct {
+	shards      []*cacheShard[K, V]
+	seed        maphash.Seed
+	ttl         time.Duration
+	mode        ExpiryMode
+	interval    time.Duration
+	close       chan struct{}
+	stopped     chan struct{}
+	stop        func() bool
+	once        sync.Once
+	callbacks   callbackGroup
+	size        func(K, V) int64
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	puts        atomic.Uint64
+	deletes     atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+	onEvict     atomic.Pointer[func(K, V, EvictReason)]
+	errorTTL    time.Duration
+	codec       Codec
+	snapshot    string
+}
+
+// Cache is an in memory cache that maps string keys to values of any type.
+//
+// Prefer TypedCache where the type of the cached values is known, as it avoids type assertions on retrieval and can
+// distinguish a missing value from a stored nil.
 type Cache struct {
-	data     map[string]*cacheItem
-	mu       sync.Mutex
-	ttl      time.Duration
-	interval time.Duration
-	close    chan bool
-	once     sync.Once
+	*TypedCache[string, any]
+}
+
+// NewTypedCache returns a new in memory only type safe cache that self cleans expired data at the given cleanInterval.
+// The expiry of an item in the cache is determined by the ttl, unless put with its own ttl.
+func NewTypedCache[K comparable, V any](ttl, cleanInterval time.Duration, opts ...CacheOption) *TypedCache[K, V] {
+	return NewTypedCacheContext[K, V](context.Background(), ttl, cleanInterval, opts...)
+}
+
+// NewTypedCacheContext returns a new in memory only type safe cache like NewTypedCache that is closed once the given
+// context is done.
+func NewTypedCacheContext[K comparable, V any](ctx context.Context, ttl, cleanInterval time.Duration, opts ...CacheOption) *TypedCache[K, V] {
+	return newTypedCache[K, V](ctx, ttl, cleanInterval, 0, nil, opts...)
+}
+
+// NewSizedTypedCache returns a new in memory only type safe cache like NewTypedCache that is bounded to hold entries
+// with a combined size of at most maxBytes bytes, evicting entries according to the eviction policy of the cache once
+// full. The size of each entry is determined by the given size function. An entry that is larger than maxBytes by
+// itself is not stored.
+func NewSizedTypedCache[K comparable, V any](ttl, cleanInterval time.Duration, maxBytes int64, size func(k K, v V) int64, opts ...CacheOption) *TypedCache[K, V] {
+	return NewSizedTypedCacheContext(context.Background(), ttl, cleanInterval, maxBytes, size, opts...)
+}
+
+// NewSizedTypedCacheContext returns a new in memory only type safe cache like NewSizedTypedCache that is closed once
+// the given context is done.
+func NewSizedTypedCacheContext[K comparable, V any](ctx context.Context, ttl, cleanInterval time.Duration, maxBytes int64, size func(k K, v V) int64, opts ...CacheOption) *TypedCache[K, V] {
+	return newTypedCache(ctx, ttl, cleanInterval, maxBytes, size, opts...)
+}
+
+// newTypedCache returns a new cache bounded to maxBytes by the size function if both are set.
+func newTypedCache[K comparable, V any](ctx context.Context, ttl, cleanInterval time.Duration, maxBytes int64, size func(K, V) int64, opts ...CacheOption) *TypedCache[K, V] {
+	var cfg cacheConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	cache := &TypedCache[K, V]{
+		seed:     maphash.MakeSeed(),
+		ttl:      ttl,
+		mode:     cfg.mode,
+		interval: cleanInterval,
+		close:    make(chan struct{}),
+		stopped:  make(chan struct{}),
+		errorTTL: cfg.errorTTL,
+		codec:    IIF[Codec](cfg.codec == nil, GobCodec{}, cfg.codec),
+		snapshot: cfg.snapshot,
+	}
+
+	if size == nil || maxBytes < 1 {
+		maxBytes = 0
+	} else {
+		cache.size = size
+	}
+
+	bounded := cfg.maxEntries > 0 || maxBytes > 0
+	n := cfg.shards
+	if n < 1 {
+		n = IIF(bounded, 1, 4*runtime.GOMAXPROCS(0))
+	}
+
+	cache.shards = make([]*cacheShard[K, V], nextPowerOfTwo(n))
+	for i := range cache.shards {
+		shard := &cacheShard[K, V]{
+			data:     make(map[K]*cacheItem[K, V]),
+			loads:    make(map[K]*loadCall[V]),
+			failures: make(map[K]loadFailure),
+			tags:     make(map[string]map[K]struct{}),
+		}
+
+		if bounded {
+			shard.maxEntries = splitLimit(cfg.maxEntries, len(cache.shards))
+			shard.maxBytes = splitLimit(maxBytes, len(cache.shards))
+			shard.evictor = newEvictor[K, V](cfg.policy)
+		}
+
+		cache.shards[i] = shard
+	}
+
+	if cache.snapshot != "" {
+		if err := cache.restoreFile(); err != nil {
+			log.Printf("cache snapshot could not be restored: %v", err)
+		}
+	}
+
+	// start the cleaning goroutine
+	go cache.cleaner()
+	cache.stop = context.AfterFunc(ctx, cache.closeAndWait)
+
+	return cache
+}
+
+// NewCache returns a new in memory only cache that self cleans expired data at the given cleanInterval.
+// The expiry of an item in the cache is determined by the ttl, unless put with its own ttl.
+func NewCache(ttl, cleanInterval time.Duration, opts ...CacheOption) *Cache {
+	return &Cache{TypedCache: NewTypedCache[string, any](ttl, cleanInterval, opts...)}
+}
+
+// NewCacheContext returns a new in memory only cache like NewCache that is closed once the given context is done.
+func NewCacheContext(ctx context.Context, ttl, cleanInterval time.Duration, opts ...CacheOption) *Cache {
+	return &Cache{TypedCache: NewTypedCacheContext[string, any](ctx, ttl, cleanInterval, opts...)}
+}
+
+// NewSizedCache returns a new in memory only cache like NewCache that is bounded to hold entries with a combined size
+// of at most maxBytes bytes, as with NewSizedTypedCache.
+func NewSizedCache(ttl, cleanInterval time.Duration, maxBytes int64, size func(k string, v any) int64, opts ...CacheOption) *Cache {
+	return &Cache{TypedCache: NewSizedTypedCache(ttl, cleanInterval, maxBytes, size, opts...)}
+}
+
+// NewSizedCacheContext returns a new in memory only cache like NewSizedCache that is closed once the given context is
+// done.
+func NewSizedCacheContext(ctx context.Context, ttl, cleanInterval time.Duration, maxBytes int64, size func(k string, v any) int64, opts ...CacheOption) *Cache {
+	return &Cache{TypedCache: NewSizedTypedCacheContext(ctx, ttl, cleanInterval, maxBytes, size, opts...)}
 }
 
-// Close signals for the cache to gracefully stop the goroutine that periodically cleans expired data.
-func (c *Cache) Close() {
+// nextPowerOfTwo returns the smallest power of two greater than or equal to n.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+
+	return p
+}
+
+// splitLimit divides the limit evenly between n shards, rounding up so that no shard is left without capacity.
+func splitLimit[T int | int64](limit T, n int) T {
+	if limit <= 0 {
+		return limit
+	}
+
+	return (limit + T(n) - 1) / T(n)
+}
+
+// shard returns the shard that holds the key k.
+func (c *TypedCache[K, V]) shard(k K) *cacheShard[K, V] {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+
+	return c.shards[maphash.Comparable(c.seed, k)&uint64(len(c.shards)-1)]
+}
+
+// Close gracefully stops the goroutine that periodically cleans expired data and waits for it to exit.
+// If the cache was created with WithSnapshotFile, a snapshot is saved before any entries remaining in the cache are
+// removed with the reason EvictClosed. Close then waits for all running eviction callbacks to return. Values put once
+// Close has begun are never stored, and are instead passed to the eviction callback with the reason EvictClosed.
+//
+// Close is safe to call concurrently and more than once, where every call waits for the cache to finish closing.
+// It must not be called from an eviction callback.
+func (c *TypedCache[K, V]) Close() {
+	c.stop()
+	c.closeAndWait()
+}
+
+// closeAndWait closes the cache and waits for all running eviction callbacks to return. It is called once the context
+// of the cache is done, which may be before stop has been assigned, so it must not call stop.
+func (c *TypedCache[K, V]) closeAndWait() {
+	c.shutdown()
+	<-c.callbacks.wait()
+}
+
+// Shutdown closes the cache like Close, but returns the error of the given context if it is done before the cache has
+// finished closing. Closing continues in the background in that case.
+func (c *TypedCache[K, V]) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown stops the cleaner, saves the snapshot, and removes the remaining entries once.
+func (c *TypedCache[K, V]) shutdown() {
 	c.once.Do(func() {
-		c.close <- true
 		close(c.close)
+		<-c.stopped
+
+		if c.snapshot != "" {
+			if err := c.saveFile(); err != nil {
+				log.Printf("cache snapshot could not be saved: %v", err)
+			}
+		}
+
+		for _, s := range c.shards {
+			var evicted []eviction[K, V]
+
+			s.mu.Lock()
+			s.closed = true
+			for _, v := range s.data {
+				evicted = c.remove(s, v, EvictClosed, evicted)
+			}
+			s.mu.Unlock()
+
+			c.notify(evicted)
+		}
 	})
 }
 
+// OnEvict sets the callback that is called with the key, value, and reason of every entry removed from the cache,
+// replacing any previously set callback. The callback is called after the cache lock is released, so it may safely
+// use the cache.
+func (c *TypedCache[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	c.onEvict.Store(&fn)
+}
+
 // cleaner is a goroutine function that removes expired data from the cache at the specified cache clean interval.
-func (c *Cache) cleaner() {
+// Each shard is swept in turn so that only one shard is locked at a time.
+func (c *TypedCache[K, V]) cleaner() {
+	defer close(c.stopped)
+
 	ticker := time.NewTicker(c.interval)
 	defer ticker.Stop()
 
@@ -38,60 +490,244 @@ func (c *Cache) cleaner() {
 			return
 
 		case now := <-ticker.C:
-			c.mu.Lock()
-			for k, v := range c.data {
-				if now.Sub(v.lastAccess) > c.ttl {
-					delete(c.data, k)
-				}
+			for _, s := range c.shards {
+				c.sweep(s, now)
 			}
-			c.mu.Unlock()
 		}
 	}
 }
 
-// NewCache returns a new in memory only cache that self cleans expired data at the given cleanInterval.
-// The expiry of an item in the cache is determined by the ttl.
-func NewCache(ttl, cleanInterval time.Duration) *Cache {
-	cache := &Cache{
-		data:     make(map[string]*cacheItem),
-		mu:       sync.Mutex{},
-		ttl:      ttl,
-		interval: cleanInterval,
-		close:    make(chan bool),
+// sweep removes the expired data from the shard at the given time.
+func (c *TypedCache[K, V]) sweep(s *cacheShard[K, V], now time.Time) {
+	var evicted []eviction[K, V]
+
+	s.mu.Lock()
+	for _, v := range s.data {
+		if v.expired(now) {
+			evicted = c.remove(s, v, EvictExpired, evicted)
+		}
+	}
+	for k, f := range s.failures {
+		if now.After(f.expiresAt) {
+			delete(s.failures, k)
+		}
 	}
+	s.mu.Unlock()
 
-	// start the cleaning goroutine
-	go cache.cleaner()
+	c.notify(evicted)
+}
 
-	return cache
+// remove removes the item from the shard for the given reason and returns evicted with the item appended if an
+// eviction callback is set. The caller must hold the shard lock.
+func (c *TypedCache[K, V]) remove(s *cacheShard[K, V], item *cacheItem[K, V], reason EvictReason, evicted []eviction[K, V]) []eviction[K, V] {
+	delete(s.data, item.key)
+	s.bytes -= item.size
+	if s.evictor != nil {
+		s.evictor.remove(item)
+	}
+
+	for _, tag := range item.tags {
+		delete(s.tags[tag], item.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+
+	switch reason {
+	case EvictExpired:
+		c.expirations.Add(1)
+	case EvictCapacity:
+		c.evictions.Add(1)
+	case EvictDeleted:
+		c.deletes.Add(1)
+	}
+
+	if c.onEvict.Load() != nil {
+		evicted = append(evicted, eviction[K, V]{key: item.key, value: item.value, reason: reason})
+	}
+
+	return evicted
+}
+
+// notify calls the eviction callback, if set, for each of the evicted entries. The caller must not hold any shard
+// lock.
+func (c *TypedCache[K, V]) notify(evicted []eviction[K, V]) {
+	fn := c.onEvict.Load()
+	if fn == nil || len(evicted) == 0 {
+		return
+	}
+
+	c.callbacks.add()
+	defer c.callbacks.done()
+
+	for _, e := range evicted {
+		(*fn)(e.key, e.value, e.reason)
+	}
+}
+
+// makeRoom evicts items from the shard until an item of the given size fits within its bounds and returns evicted
+// with the evicted items appended. The caller must hold the shard lock.
+func (c *TypedCache[K, V]) makeRoom(s *cacheShard[K, V], size int64, evicted []eviction[K, V]) []eviction[K, V] {
+	for len(s.data) > 0 {
+		full := s.maxEntries > 0 && len(s.data) >= s.maxEntries
+		if s.maxBytes > 0 && s.bytes+size > s.maxBytes {
+			full = true
+		}
+
+		if !full {
+			break
+		}
+
+		evicted = c.remove(s, s.evictor.victim(), EvictCapacity, evicted)
+	}
+
+	return evicted
 }
 
-// Put adds the value v to the cache with key k.
-func (c *Cache) Put(k string, v any) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Put adds the value v to the cache with key k using the ttl and expiry mode of the cache.
+func (c *TypedCache[K, V]) Put(k K, v V) {
+	c.PutWithExpiry(k, v, c.ttl, c.mode)
+}
 
-	c.data[k] = &cacheItem{value: v, lastAccess: time.Now()}
+// PutWithTTL adds the value v to the cache with key k that expires after the given ttl, using the expiry mode of the
+// cache.
+func (c *TypedCache[K, V]) PutWithTTL(k K, v V, ttl time.Duration) {
+	c.PutWithExpiry(k, v, ttl, c.mode)
 }
 
-// Get retrieves the value v from the cache with key k if it exists and is not expired.
-func (c *Cache) Get(k string) (v any) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// PutWithExpiry adds the value v to the cache with key k that expires after the given ttl using the given expiry mode.
+//
+// If the cache is bounded and full, entries are evicted according to the eviction policy of the cache to make room.
+func (c *TypedCache[K, V]) PutWithExpiry(k K, v V, ttl time.Duration, mode ExpiryMode) {
+	now := time.Now()
+	c.put(&cacheItem[K, V]{
+		key:        k,
+		lastAccess: now,
+		expiresAt:  now.Add(ttl),
+		ttl:        ttl,
+		mode:       mode,
+		value:      v,
+	})
+}
+
+// put adds the item to the cache, replacing any item with the same key and evicting items to make room if needed.
+func (c *TypedCache[K, V]) put(item *cacheItem[K, V]) {
+	c.puts.Add(1)
+	item.created = item.lastAccess
+	if c.size != nil {
+		item.size = c.size(item.key, item.value)
+	}
+
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	s := c.shard(item.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		// the cache has closed, so the value is handed straight back rather than left without a callback
+		if c.onEvict.Load() != nil {
+			evicted = append(evicted, eviction[K, V]{key: item.key, value: item.value, reason: EvictClosed})
+		}
+		return
+	}
+
+	delete(s.failures, item.key)
+	if old, ok := s.data[item.key]; ok {
+		evicted = c.remove(s, old, EvictReplaced, evicted)
+	}
+
+	if s.evictor != nil {
+		if s.maxBytes > 0 && item.size > s.maxBytes {
+			c.evictions.Add(1)
+			if c.onEvict.Load() != nil {
+				evicted = append(evicted, eviction[K, V]{key: item.key, value: item.value, reason: EvictCapacity})
+			}
+			return
+		}
+
+		evicted = c.makeRoom(s, item.size, evicted)
+		s.evictor.push(item)
+	}
+
+	s.data[item.key] = item
+	s.bytes += item.size
+
+	for _, tag := range item.tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[K]struct{})
+		}
+		s.tags[tag][item.key] = struct{}{}
+	}
+}
+
+// Get retrieves the value v from the cache with key k and true if it exists and is not expired, otherwise the zero
+// value of V and false.
+func (c *TypedCache[K, V]) Get(k K) (v V, ok bool) {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	data, ok := c.data[k]
-	if ok && time.Since(data.lastAccess) <= c.ttl {
-		v = data.value
-		data.lastAccess = time.Now()
+	var data *cacheItem[K, V]
+	data, evicted = c.lookup(s, k, evicted)
+	if data == nil {
+		return v, false
 	}
 
+	return data.value, true
+}
+
+// lookup returns the unexpired item with key k from the shard, recording the access, or nil if it does not exist. An
+// expired item is removed and appended to evicted. The caller must hold the shard lock.
+func (c *TypedCache[K, V]) lookup(s *cacheShard[K, V], k K, evicted []eviction[K, V]) (*cacheItem[K, V], []eviction[K, V]) {
+	now := time.Now()
+	data, ok := s.data[k]
+	if !ok {
+		c.misses.Add(1)
+		return nil, evicted
+	}
+
+	if data.expired(now) {
+		c.misses.Add(1)
+		return nil, c.remove(s, data, EvictExpired, evicted)
+	}
+
+	c.hits.Add(1)
+	data.touch(now)
+	if s.evictor != nil {
+		s.evictor.touch(data)
+	}
+
+	return data, evicted
+}
+
+// Get retrieves the value v from the cache with key k if it exists and is not expired.
+func (c *Cache) Get(k string) (v any) {
+	v, _ = c.TypedCache.Get(k)
 	return
 }
 
+// Store returns the cache as a Store[string, any], for use where a Store is taken. The Store shares its entries with
+// the cache.
+func (c *Cache) Store() Store[string, any] {
+	return c.TypedCache
+}
+
 // Delete removes the key value pair mapped to by k if it exists.
-func (c *Cache) Delete(k string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *TypedCache[K, V]) Delete(k K) {
+	var evicted []eviction[K, V]
+	defer func() { c.notify(evicted) }()
+
+	s := c.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	delete(c.data, k)
+	delete(s.failures, k)
+	if item, ok := s.data[k]; ok {
+		evicted = c.remove(s, item, EvictDeleted, evicted)
+	}
 }
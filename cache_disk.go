@@ -0,0 +1,236 @@
+package weblib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// diskMagic identifies the files written by a DiskStore and the version of their format.
+const diskMagic = "wld1"
+
+// diskHeaderSize is the size of the header at the start of each file in a DiskStore, holding the magic followed by the
+// expiry.
+const diskHeaderSize = len(diskMagic) + 8
+
+// DiskStore is a ByteStore that holds each entry in its own file in a directory on local disk.
+//
+// Entries survive restarts of the process, and the directory may be shared by processes on the same machine. The
+// directory must be dedicated to the store, as the cleaner removes expired files it takes to be entries. Only files
+// named as entries and starting with the header of an entry are considered.
+type DiskStore struct {
+	dir      string
+	interval time.Duration
+	close    chan struct{}
+	once     sync.Once
+}
+
+var _ ByteStore = (*DiskStore)(nil)
+
+// NewDiskStore returns a new ByteStore that holds entries as files in the given directory, creating it if it does not
+// exist, and self cleans expired files at the given cleanInterval.
+func NewDiskStore(dir string, cleanInterval time.Duration) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create disk store directory: %w", err)
+	}
+
+	store := &DiskStore{
+		dir:      dir,
+		interval: cleanInterval,
+		close:    make(chan struct{}),
+	}
+
+	// start the cleaning goroutine
+	go store.cleaner()
+
+	return store, nil
+}
+
+// NewDiskCache returns a new Store that holds values of type V as files in the given directory, encoded by the given
+// codec. The default expiry of an entry is determined by the ttl, and expired files are cleaned at the given
+// cleanInterval.
+func NewDiskCache[V any](dir string, ttl, cleanInterval time.Duration, codec Codec) (*CodecStore[V], error) {
+	store, err := NewDiskStore(dir, cleanInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewCodecStore[V](store, codec, ttl), nil
+}
+
+// path returns the path of the file holding the entry with the given key. Keys are hashed so that any key maps to a
+// valid file name.
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// read returns the value held in the file at path and true if it is not expired, otherwise nil and false.
+func (s *DiskStore) read(path string, now time.Time) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	expiresAt, ok := diskExpiry(data)
+	if !ok {
+		return nil, false, fmt.Errorf("corrupt cache file %s", path)
+	}
+
+	if expiresAt != 0 && now.UnixNano() > expiresAt {
+		return nil, false, nil
+	}
+
+	return data[diskHeaderSize:], true, nil
+}
+
+// expired returns the info of the file at path and true if the entry it holds has expired, reading only its header.
+func (s *DiskStore) expired(path string, now time.Time) (fs.FileInfo, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false
+	}
+
+	header := make([]byte, diskHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil, false
+	}
+
+	expiresAt, ok := diskExpiry(header)
+	return info, ok && expiresAt != 0 && now.UnixNano() > expiresAt
+}
+
+// diskExpiry returns the expiry in the header at the start of data and true if it starts with the header of an entry.
+func diskExpiry(data []byte) (int64, bool) {
+	if len(data) < diskHeaderSize || string(data[:len(diskMagic)]) != diskMagic {
+		return 0, false
+	}
+
+	return int64(binary.BigEndian.Uint64(data[len(diskMagic):])), true
+}
+
+// isDiskEntry returns true if the file name is in the format of the files returned by path.
+func isDiskEntry(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// Get retrieves the value with the given key and true if it exists and is not expired. Expired files are left for the
+// cleaner to remove.
+func (s *DiskStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.read(s.path(key), time.Now())
+}
+
+// Set writes the value with the given key to disk, expiring after the given ttl. The file is written atomically, so
+// concurrent readers see either the old or the new value.
+func (s *DiskStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	header := make([]byte, diskHeaderSize)
+	copy(header, diskMagic)
+	binary.BigEndian.PutUint64(header[len(diskMagic):], uint64(expiresAt))
+
+	_, err = tmp.Write(append(header, value...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path(key))
+}
+
+// Delete removes the value with the given key if it exists.
+func (s *DiskStore) Delete(ctx context.Context, key string) error {
+	return s.remove(s.path(key))
+}
+
+// remove removes the file at path, ignoring it if it does not exist.
+func (s *DiskStore) remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// Close signals for the store to gracefully stop the goroutine that periodically cleans expired files. The files of
+// unexpired entries are left on disk.
+func (s *DiskStore) Close() error {
+	s.once.Do(func() { close(s.close) })
+
+	return nil
+}
+
+// cleaner is a goroutine function that removes expired files from the store at the specified clean interval.
+func (s *DiskStore) cleaner() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.close:
+			return
+
+		case now := <-ticker.C:
+			entries, err := os.ReadDir(s.dir)
+			if err != nil {
+				log.Printf("disk store clean failed: %v", err)
+				continue
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() || !isDiskEntry(entry.Name()) {
+					continue
+				}
+
+				path := filepath.Join(s.dir, entry.Name())
+				info, ok := s.expired(path, now)
+				if !ok {
+					continue
+				}
+
+				// skip entries written again since their header was read. A Set that renames its file in between the
+				// check and the removal is still lost, which is a miss like any other for a cache.
+				current, err := os.Stat(path)
+				if err == nil && os.SameFile(info, current) && current.ModTime().Equal(info.ModTime()) &&
+					current.Size() == info.Size() {
+					s.remove(path)
+				}
+			}
+		}
+	}
+}
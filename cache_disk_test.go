@@ -0,0 +1,96 @@
+package weblib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskStore_Expiration(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create disk store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Set(ctx, "short", []byte("value"), 200*time.Millisecond); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	if err := store.Set(ctx, "forever", []byte("value"), 0); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	if _, ok, err := store.Get(ctx, "short"); ok || err != nil {
+		t.Errorf("expected short to have expired, got %v and %v", ok, err)
+	}
+
+	if v, ok, err := store.Get(ctx, "forever"); !ok || err != nil || string(v) != "value" {
+		t.Errorf("expected forever to remain, got %q, %v and %v", v, ok, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected cleaner to leave 1 file, got %d", len(entries))
+	}
+}
+
+func TestDiskStore_Persists(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	store, err := NewDiskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create disk store: %v", err)
+	}
+	store.Set(ctx, "key", []byte("value"), time.Minute)
+	store.Close()
+
+	reopened, err := NewDiskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to reopen disk store: %v", err)
+	}
+	defer reopened.Close()
+
+	if v, ok, err := reopened.Get(ctx, "key"); !ok || err != nil || string(v) != "value" {
+		t.Errorf("expected value to persist, got %q, %v and %v", v, ok, err)
+	}
+}
+
+func TestDiskStore_CleanerKeepsForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// neither file is an entry, though both start with 8 bytes that decode to an expiry in the past
+	foreign := map[string][]byte{
+		"logo.png":              []byte("\x89PNG\r\n\x1a\n"),
+		strings.Repeat("0", 64): []byte(strings.Repeat("\xff", 16)),
+	}
+	for name, data := range foreign {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	store, err := NewDiskStore(dir, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create disk store: %v", err)
+	}
+	defer store.Close()
+
+	time.Sleep(200 * time.Millisecond)
+
+	for name := range foreign {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected cleaner to keep %s, got %v", name, err)
+		}
+	}
+}
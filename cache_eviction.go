@@ -0,0 +1,108 @@
+package weblib
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+// EvictionPolicy determines which entry is evicted from a bounded cache once full.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU EvictionPolicy = iota
+
+	// LFU evicts the least frequently used entry, breaking ties by evicting the least recently used entry.
+	LFU
+)
+
+// evictor tracks the usage of cache items to choose a victim for eviction. The caller must hold the cache lock.
+type evictor[K comparable, V any] interface {
+	push(item *cacheItem[K, V])
+	touch(item *cacheItem[K, V])
+	remove(item *cacheItem[K, V])
+	victim() *cacheItem[K, V]
+}
+
+// newEvictor returns the evictor implementing the given policy.
+func newEvictor[K comparable, V any](policy EvictionPolicy) evictor[K, V] {
+	if policy == LFU {
+		return &lfuEvictor[K, V]{}
+	}
+
+	return &lruEvictor[K, V]{list: list.New()}
+}
+
+// lruEvictor orders items from most to least recently used.
+type lruEvictor[K comparable, V any] struct {
+	list *list.List
+}
+
+func (e *lruEvictor[K, V]) push(item *cacheItem[K, V]) {
+	item.elem = e.list.PushFront(item)
+}
+
+func (e *lruEvictor[K, V]) touch(item *cacheItem[K, V]) {
+	e.list.MoveToFront(item.elem)
+}
+
+func (e *lruEvictor[K, V]) remove(item *cacheItem[K, V]) {
+	e.list.Remove(item.elem)
+	item.elem = nil
+}
+
+func (e *lruEvictor[K, V]) victim() *cacheItem[K, V] {
+	return e.list.Back().Value.(*cacheItem[K, V])
+}
+
+// lfuEvictor is a min heap of items ordered by hits, then by last access.
+type lfuEvictor[K comparable, V any] []*cacheItem[K, V]
+
+func (e lfuEvictor[K, V]) Len() int {
+	return len(e)
+}
+
+func (e lfuEvictor[K, V]) Less(i, j int) bool {
+	if e[i].hits != e[j].hits {
+		return e[i].hits < e[j].hits
+	}
+
+	return e[i].lastAccess.Before(e[j].lastAccess)
+}
+
+func (e lfuEvictor[K, V]) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].index = i
+	e[j].index = j
+}
+
+func (e *lfuEvictor[K, V]) Push(x any) {
+	item := x.(*cacheItem[K, V])
+	item.index = len(*e)
+	*e = append(*e, item)
+}
+
+func (e *lfuEvictor[K, V]) Pop() any {
+	old := *e
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*e = old[:len(old)-1]
+	item.index = -1
+	return item
+}
+
+func (e *lfuEvictor[K, V]) push(item *cacheItem[K, V]) {
+	heap.Push(e, item)
+}
+
+func (e *lfuEvictor[K, V]) touch(item *cacheItem[K, V]) {
+	heap.Fix(e, item.index)
+}
+
+func (e *lfuEvictor[K, V]) remove(item *cacheItem[K, V]) {
+	heap.Remove(e, item.index)
+}
+
+func (e *lfuEvictor[K, V]) victim() *cacheItem[K, V] {
+	return (*e)[0]
+}
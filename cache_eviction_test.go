@@ -0,0 +1,115 @@
+package weblib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_MaxEntriesLRU(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second, WithMaxEntries(2))
+	defer cache.Close()
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Get("a")
+	cache.Put("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected least recently used key b to be evicted")
+	}
+
+	for _, k := range []string{"a", "c"} {
+		if _, ok := cache.Get(k); !ok {
+			t.Errorf("expected key %s to remain", k)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Evictions != 1 {
+		t.Errorf("expected 1 eviction, got %d", stats.Evictions)
+	}
+}
+
+func TestCache_MaxEntriesLFU(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second, WithMaxEntries(2), WithEvictionPolicy(LFU))
+	defer cache.Close()
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	cache.Put("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected least frequently used key b to be evicted")
+	}
+
+	for _, k := range []string{"a", "c"} {
+		if _, ok := cache.Get(k); !ok {
+			t.Errorf("expected key %s to remain", k)
+		}
+	}
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	size := func(k string, v []byte) int64 {
+		return int64(len(v))
+	}
+
+	cache := NewSizedTypedCache(5*time.Second, 5*time.Second, 10, size)
+	defer cache.Close()
+
+	cache.Put("a", make([]byte, 4))
+	cache.Put("b", make([]byte, 4))
+	cache.Put("c", make([]byte, 4))
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected key a to be evicted")
+	}
+
+	cache.Put("d", make([]byte, 11))
+	if _, ok := cache.Get("d"); ok {
+		t.Error("expected key d larger than the cache to not be stored")
+	}
+
+	for _, k := range []string{"b", "c"} {
+		if _, ok := cache.Get(k); !ok {
+			t.Errorf("expected key %s to remain", k)
+		}
+	}
+
+	if stats := cache.Stats(); stats.Evictions != 2 {
+		t.Errorf("expected 2 evictions, got %d", stats.Evictions)
+	}
+}
+
+func TestCache_SizedCache(t *testing.T) {
+	cache := NewSizedCache(5*time.Second, 5*time.Second, 10, func(k string, v any) int64 {
+		return int64(len(v.([]byte)))
+	})
+	defer cache.Close()
+
+	cache.Put("a", make([]byte, 6))
+	cache.Put("b", make([]byte, 6))
+
+	if cache.Get("a") != nil {
+		t.Error("expected key a to be evicted")
+	}
+
+	if stats := cache.Stats(); stats.Bytes != 6 {
+		t.Errorf("expected 6 bytes, got %d", stats.Bytes)
+	}
+}
+
+func TestCache_Expirations(t *testing.T) {
+	cache := NewCache(200*time.Millisecond, 100*time.Millisecond)
+	defer cache.Close()
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	time.Sleep(500 * time.Millisecond)
+
+	if stats := cache.Stats(); stats.Expirations != 2 {
+		t.Errorf("expected 2 expirations, got %d", stats.Expirations)
+	}
+}
@@ -0,0 +1,77 @@
+package weblib
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// PutTagged adds the value v to the cache with key k using the ttl and expiry mode of the cache, tagging it with the
+// given tags so that it can be removed with InvalidateTag.
+func (c *TypedCache[K, V]) PutTagged(k K, v V, tags ...string) {
+	now := time.Now()
+	c.put(&cacheItem[K, V]{
+		key:        k,
+		lastAccess: now,
+		expiresAt:  now.Add(c.ttl),
+		ttl:        c.ttl,
+		mode:       c.mode,
+		value:      v,
+		tags:       slices.Compact(slices.Sorted(slices.Values(tags))),
+	})
+}
+
+// InvalidateTag removes every entry tagged with the given tag and returns the number of entries removed.
+func (c *TypedCache[K, V]) InvalidateTag(tag string) int {
+	removed := 0
+	for _, s := range c.shards {
+		var evicted []eviction[K, V]
+
+		s.mu.Lock()
+		for k := range s.tags[tag] {
+			evicted = c.remove(s, s.data[k], EvictDeleted, evicted)
+			removed++
+		}
+		s.mu.Unlock()
+
+		c.notify(evicted)
+	}
+
+	return removed
+}
+
+// DeleteFunc removes every entry for which fn returns true and returns the number of entries removed. The function is
+// called with the lock of a shard held, so it must not use the cache.
+func (c *TypedCache[K, V]) DeleteFunc(fn func(k K, v V) bool) int {
+	removed := 0
+	for _, s := range c.shards {
+		var evicted []eviction[K, V]
+
+		s.mu.Lock()
+		for k, item := range s.data {
+			if fn(k, item.value) {
+				evicted = c.remove(s, item, EvictDeleted, evicted)
+				removed++
+			}
+		}
+		s.mu.Unlock()
+
+		c.notify(evicted)
+	}
+
+	return removed
+}
+
+// DeletePrefix removes every entry of the cache with a key that starts with the given prefix and returns the number of
+// entries removed.
+func DeletePrefix[V any](c *TypedCache[string, V], prefix string) int {
+	return c.DeleteFunc(func(k string, _ V) bool {
+		return strings.HasPrefix(k, prefix)
+	})
+}
+
+// DeletePrefix removes every entry with a key that starts with the given prefix and returns the number of entries
+// removed.
+func (c *Cache) DeletePrefix(prefix string) int {
+	return DeletePrefix(c.TypedCache, prefix)
+}
@@ -0,0 +1,70 @@
+package weblib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_InvalidateTag(t *testing.T) {
+	cache := NewCache(time.Minute, time.Minute, WithShards(4))
+	defer cache.Close()
+
+	cache.PutTagged("profile:1", "a", "user:1")
+	cache.PutTagged("feed:1", "b", "user:1", "user:2")
+	cache.PutTagged("feed:2", "c", "user:2")
+	cache.Put("other", "d")
+
+	if n := cache.InvalidateTag("user:1"); n != 2 {
+		t.Errorf("expected 2 entries invalidated, got %d", n)
+	}
+
+	for _, k := range []string{"profile:1", "feed:1"} {
+		if v := cache.Get(k); v != nil {
+			t.Errorf("expected %s to be invalidated, got %v", k, v)
+		}
+	}
+
+	for _, k := range []string{"feed:2", "other"} {
+		if v := cache.Get(k); v == nil {
+			t.Errorf("expected %s to remain", k)
+		}
+	}
+
+	// the removed feed:1 entry is no longer indexed under its other tag
+	if n := cache.InvalidateTag("user:2"); n != 1 {
+		t.Errorf("expected 1 entry invalidated, got %d", n)
+	}
+}
+
+func TestCache_PutTaggedReplaced(t *testing.T) {
+	cache := NewCache(time.Minute, time.Minute)
+	defer cache.Close()
+
+	cache.PutTagged("key", "a", "old")
+	cache.Put("key", "b")
+
+	if n := cache.InvalidateTag("old"); n != 0 {
+		t.Errorf("expected replaced entry to lose its tags, got %d invalidated", n)
+	}
+
+	if v := cache.Get("key"); v != "b" {
+		t.Errorf("expected b, got %v", v)
+	}
+}
+
+func TestCache_DeletePrefix(t *testing.T) {
+	cache := NewCache(time.Minute, time.Minute, WithShards(4))
+	defer cache.Close()
+
+	cache.Put("user:1:profile", 1)
+	cache.Put("user:1:feed", 2)
+	cache.Put("user:10:profile", 3)
+
+	if n := cache.DeletePrefix("user:1:"); n != 2 {
+		t.Errorf("expected 2 entries deleted, got %d", n)
+	}
+
+	if v := cache.Get("user:10:profile"); v != 3 {
+		t.Errorf("expected user:10:profile to remain, got %v", v)
+	}
+}
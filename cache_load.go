@@ -0,0 +1,100 @@
+package weblib
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errLoaderPanicked is returned to callers waiting on a load whose loader panicked.
+var errLoaderPanicked = errors.New("weblib: cache loader panicked")
+
+// loadCall is an in flight call of a loader shared by concurrent callers of GetOrLoad for the same key.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// loadFailure is a cached loader error.
+type loadFailure struct {
+	err       error
+	expiresAt time.Time
+}
+
+// GetOrLoad retrieves the value from the cache with key k if it exists and is not expired, otherwise the value is
+// loaded by calling the loader and put in the cache.
+//
+// Concurrent calls for the same key share a single call of the loader, which is given the context of the caller that
+// started it. Callers waiting on a load in progress return early with the error of their own context if it is done
+// first, and load again themselves if the load fails with the error of a context while their own is not done. Loader
+// errors are returned without being cached unless the cache was created with WithLoadErrorTTL, and context errors are
+// never cached.
+func (c *TypedCache[K, V]) GetOrLoad(ctx context.Context, k K, loader func(ctx context.Context) (V, error)) (V, error) {
+	s := c.shard(k)
+	s.mu.Lock()
+
+	data, evicted := c.lookup(s, k, nil)
+	if data != nil {
+		s.mu.Unlock()
+		c.notify(evicted)
+		return data.value, nil
+	}
+
+	if f, ok := s.failures[k]; ok {
+		if time.Now().After(f.expiresAt) {
+			delete(s.failures, k)
+		} else {
+			s.mu.Unlock()
+			c.notify(evicted)
+			var zero V
+			return zero, f.err
+		}
+	}
+
+	if call, ok := s.loads[k]; ok {
+		s.mu.Unlock()
+		c.notify(evicted)
+
+		select {
+		case <-call.done:
+			if isContextError(call.err) && ctx.Err() == nil {
+				// the load was cancelled by the context of the caller that started it rather than this one
+				return c.GetOrLoad(ctx, k, loader)
+			}
+
+			return call.value, call.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+
+	call := &loadCall[V]{done: make(chan struct{}), err: errLoaderPanicked}
+	s.loads[k] = call
+	s.mu.Unlock()
+	c.notify(evicted)
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.loads, k)
+		if call.err != nil && c.errorTTL > 0 && !isContextError(call.err) {
+			s.failures[k] = loadFailure{err: call.err, expiresAt: time.Now().Add(c.errorTTL)}
+		}
+		s.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	call.value, call.err = loader(ctx)
+	if call.err == nil {
+		c.Put(k, call.value)
+	}
+
+	return call.value, call.err
+}
+
+// isContextError returns true if err is the error of a context that was cancelled or whose deadline passed.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
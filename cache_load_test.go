@@ -0,0 +1,180 @@
+package weblib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_GetOrLoad(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		return 42, nil
+	}
+
+	for range 2 {
+		v, err := cache.GetOrLoad(context.Background(), "key", loader)
+		if err != nil || v != 42 {
+			t.Errorf("expected 42 and nil error, got %d and %v", v, err)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("expected loader to be called once, got %d", calls.Load())
+	}
+
+	if v, ok := cache.Get("key"); !ok || v != 42 {
+		t.Errorf("expected loaded value to be cached, got %d and %v", v, ok)
+	}
+}
+
+func TestCache_GetOrLoadConcurrent(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(context.Background(), "key", loader)
+			if err != nil || v != 42 {
+				t.Errorf("expected 42 and nil error, got %d and %v", v, err)
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected loader to be called once, got %d", calls.Load())
+	}
+}
+
+func TestCache_GetOrLoadError(t *testing.T) {
+	errLoad := errors.New("load failed")
+
+	tests := []struct {
+		name      string
+		opts      []CacheOption
+		wantCalls int32
+	}{
+		{name: "errors not cached by default", wantCalls: 2},
+		{name: "errors cached with ttl", opts: []CacheOption{WithLoadErrorTTL(5 * time.Second)}, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewTypedCache[string, int](5*time.Second, 5*time.Second, tt.opts...)
+			defer cache.Close()
+
+			var calls atomic.Int32
+			loader := func(ctx context.Context) (int, error) {
+				calls.Add(1)
+				return 0, errLoad
+			}
+
+			for range 2 {
+				if _, err := cache.GetOrLoad(context.Background(), "key", loader); !errors.Is(err, errLoad) {
+					t.Errorf("expected load error, got %v", err)
+				}
+			}
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("expected loader to be called %d times, got %d", tt.wantCalls, calls.Load())
+			}
+
+			if _, ok := cache.Get("key"); ok {
+				t.Error("expected failed load to not be cached as a value")
+			}
+		})
+	}
+}
+
+func TestCache_GetOrLoadContextCancelled(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second)
+	defer cache.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	go cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+		<-release
+		return 42, nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.GetOrLoad(ctx, "key", func(ctx context.Context) (int, error) {
+		t.Error("expected waiting caller to not call the loader")
+		return 0, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCache_GetOrLoadStarterCancelled(t *testing.T) {
+	cache := NewTypedCache[string, int](5*time.Second, 5*time.Second, WithLoadErrorTTL(5*time.Second))
+	defer cache.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go cache.GetOrLoad(ctx, "key", func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	// the waiter's own context is live, so it loads again once the load it waited on is cancelled
+	waited := make(chan error)
+	go func() {
+		v, err := cache.GetOrLoad(context.Background(), "key", func(ctx context.Context) (int, error) {
+			return 42, nil
+		})
+		if err == nil && v != 42 {
+			err = fmt.Errorf("expected 42, got %d", v)
+		}
+		waited <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-waited; err != nil {
+		t.Errorf("expected waiter to load the value, got %v", err)
+	}
+
+	// a load cancelled with no waiters is not cached as a failure for the callers after it
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	cache.GetOrLoad(ctx, "other", func(ctx context.Context) (int, error) {
+		return 0, ctx.Err()
+	})
+
+	if v, err := cache.GetOrLoad(context.Background(), "other", func(ctx context.Context) (int, error) {
+		return 7, nil
+	}); err != nil || v != 7 {
+		t.Errorf("expected the cancelled load to not be cached as a failure, got %d and %v", v, err)
+	}
+}
@@ -0,0 +1,122 @@
+package weblib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotEntry is the serialised form of a cache entry.
+type snapshotEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	TTL       time.Duration
+	Remaining time.Duration
+	Mode      ExpiryMode
+	Tags      []string
+}
+
+// Snapshot writes all unexpired entries of the cache, with their remaining ttl, to w using the codec of the cache.
+//
+// Shards are locked one at a time, so entries changed concurrently may or may not be included.
+func (c *TypedCache[K, V]) Snapshot(w io.Writer) error {
+	now := time.Now()
+
+	var entries []snapshotEntry[K, V]
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for _, item := range s.data {
+			if item.expired(now) {
+				continue
+			}
+
+			entries = append(entries, snapshotEntry[K, V]{
+				Key:       item.key,
+				Value:     item.value,
+				TTL:       item.ttl,
+				Remaining: item.expiresAt.Sub(now),
+				Mode:      item.mode,
+				Tags:      item.tags,
+			})
+		}
+		s.mu.Unlock()
+	}
+
+	data, err := c.codec.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache snapshot: %w", err)
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// Restore reads a snapshot written by Snapshot from r using the codec of the cache and puts its entries in the cache,
+// expiring after their remaining ttl. Entries that expired while the snapshot was stored are skipped.
+func (c *TypedCache[K, V]) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read cache snapshot: %w", err)
+	}
+
+	var entries []snapshotEntry[K, V]
+	if err := c.codec.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to decode cache snapshot: %w", err)
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		if e.Remaining <= 0 {
+			continue
+		}
+
+		// sliding entries keep their full ttl, so they are extended by it on access as before the snapshot
+		c.put(&cacheItem[K, V]{
+			key:        e.Key,
+			lastAccess: now,
+			expiresAt:  now.Add(e.Remaining),
+			ttl:        e.TTL,
+			mode:       e.Mode,
+			value:      e.Value,
+			tags:       e.Tags,
+		})
+	}
+
+	return nil
+}
+
+// restoreFile restores the cache from its snapshot file, if it exists.
+func (c *TypedCache[K, V]) restoreFile() error {
+	f, err := os.Open(c.snapshot)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Restore(f)
+}
+
+// saveFile atomically writes a snapshot of the cache to its snapshot file.
+func (c *TypedCache[K, V]) saveFile() error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.snapshot), filepath.Base(c.snapshot)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = c.Snapshot(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.snapshot)
+}
@@ -0,0 +1,67 @@
+package weblib
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache_SnapshotRestore(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+	}{
+		{name: "gob", codec: GobCodec{}},
+		{name: "json", codec: JSONCodec{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := NewTypedCache[string, int](time.Minute, time.Minute, WithCodec(tt.codec))
+			defer src.Close()
+
+			src.Put("a", 1)
+			src.PutWithExpiry("b", 2, 300*time.Millisecond, AbsoluteExpiry)
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatalf("failed to snapshot: %v", err)
+			}
+
+			dst := NewTypedCache[string, int](time.Minute, time.Minute, WithCodec(tt.codec))
+			defer dst.Close()
+
+			if err := dst.Restore(&buf); err != nil {
+				t.Fatalf("failed to restore: %v", err)
+			}
+
+			for k, want := range map[string]int{"a": 1, "b": 2} {
+				if got, ok := dst.Get(k); !ok || got != want {
+					t.Errorf("expected %s to be %d, got %d and %v", k, want, got, ok)
+				}
+			}
+
+			// the remaining ttl of b is kept rather than reset
+			time.Sleep(400 * time.Millisecond)
+			if _, ok := dst.Get("b"); ok {
+				t.Error("expected b to expire after its remaining ttl")
+			}
+		})
+	}
+}
+
+func TestCache_SnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := NewCache(time.Minute, time.Minute, WithSnapshotFile(path))
+	cache.Put("key", "value")
+	cache.Close()
+
+	restored := NewCache(time.Minute, time.Minute, WithSnapshotFile(path))
+	defer restored.Close()
+
+	if v := restored.Get("key"); v != "value" {
+		t.Errorf("expected value to be restored from snapshot file, got %v", v)
+	}
+}
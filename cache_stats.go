@@ -0,0 +1,127 @@
+package weblib
+
+import (
+	"iter"
+	"time"
+)
+
+// CacheStats is a point in time snapshot of the counters and contents of a cache.
+type CacheStats struct {
+	// Hits is the number of lookups that found an unexpired entry.
+	Hits uint64
+
+	// Misses is the number of lookups that found no entry or an expired entry.
+	Misses uint64
+
+	// Puts is the number of entries added to the cache, including replacements.
+	Puts uint64
+
+	// Deletes is the number of entries removed explicitly by Delete or invalidation.
+	Deletes uint64
+
+	// Evictions is the number of entries removed to keep a bounded cache within its limits.
+	Evictions uint64
+
+	// Expirations is the number of entries removed due to their ttl elapsing.
+	Expirations uint64
+
+	// Size is the number of entries held by the cache, including expired entries not yet cleaned.
+	Size int
+
+	// Bytes is the combined size of the entries held by a cache created by NewSizedTypedCache.
+	Bytes int64
+
+	// AverageAge is the mean time since the entries held by the cache were put.
+	AverageAge time.Duration
+}
+
+// Stats returns a snapshot of the counters and contents of the cache.
+func (c *TypedCache[K, V]) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Puts:        c.puts.Load(),
+		Deletes:     c.deletes.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+
+	now := time.Now()
+	var age time.Duration
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Size += len(s.data)
+		stats.Bytes += s.bytes
+		for _, item := range s.data {
+			age += now.Sub(item.created)
+		}
+		s.mu.Unlock()
+	}
+
+	if stats.Size > 0 {
+		stats.AverageAge = age / time.Duration(stats.Size)
+	}
+
+	return stats
+}
+
+// Len returns the number of entries held by the cache, including expired entries not yet cleaned.
+func (c *TypedCache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		n += len(s.data)
+		s.mu.Unlock()
+	}
+
+	return n
+}
+
+// All returns an iterator over the unexpired entries of the cache in no particular order.
+//
+// Iterating does not count as an access of the entries. Each shard is copied before its entries are yielded, so the
+// cache may safely be used while iterating, and entries changed concurrently may or may not be yielded.
+func (c *TypedCache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var items []*cacheItem[K, V]
+		for _, s := range c.shards {
+			now := time.Now()
+			items = items[:0]
+
+			s.mu.Lock()
+			for _, item := range s.data {
+				if !item.expired(now) {
+					items = append(items, item)
+				}
+			}
+			s.mu.Unlock()
+
+			// the key and value of an item never change once it is put, so they are safe to read unlocked
+			for _, item := range items {
+				if !yield(item.key, item.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of the unexpired entries of the cache in no particular order.
+func (c *TypedCache[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range c.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Range calls fn for each unexpired entry of the cache in no particular order until fn returns false.
+func (c *TypedCache[K, V]) Range(fn func(k K, v V) bool) {
+	for k, v := range c.All() {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
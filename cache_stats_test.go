@@ -0,0 +1,71 @@
+package weblib
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCache_Stats(t *testing.T) {
+	cache := NewTypedCache[string, int](time.Minute, time.Minute, WithMaxEntries(2))
+	defer cache.Close()
+
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("a", 3)
+	cache.Get("a")
+	cache.Get("missing")
+	cache.Delete("b")
+	cache.Put("c", 4)
+	cache.Put("d", 5)
+	cache.PutWithTTL("e", 6, -time.Second)
+	cache.Get("e")
+
+	time.Sleep(10 * time.Millisecond)
+	stats := cache.Stats()
+
+	want := CacheStats{Hits: 1, Misses: 2, Puts: 6, Deletes: 1, Evictions: 2, Expirations: 1, Size: 1}
+	got := stats
+	got.AverageAge = 0
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if stats.AverageAge < 10*time.Millisecond {
+		t.Errorf("expected average age of at least 10ms, got %v", stats.AverageAge)
+	}
+}
+
+func TestCache_Iterators(t *testing.T) {
+	cache := NewTypedCache[string, int](time.Minute, time.Minute, WithShards(4))
+	defer cache.Close()
+
+	want := map[string]int{"a": 1, "b": 2, "c": 3}
+	for k, v := range want {
+		cache.Put(k, v)
+	}
+	cache.PutWithTTL("expired", 4, -time.Second)
+
+	if cache.Len() != 4 {
+		t.Errorf("expected length 4 including the uncleaned expired entry, got %d", cache.Len())
+	}
+
+	if got := maps.Collect(cache.All()); !maps.Equal(got, want) {
+		t.Errorf("expected All to yield %v, got %v", want, got)
+	}
+
+	if got := slices.Sorted(cache.Keys()); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("expected Keys to yield a, b, c, got %v", got)
+	}
+
+	n := 0
+	cache.Range(func(k string, v int) bool {
+		n++
+		cache.Get(k) // the cache may be used while iterating
+		return false
+	})
+	if n != 1 {
+		t.Errorf("expected Range to stop after 1 entry, got %d", n)
+	}
+}
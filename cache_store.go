@@ -0,0 +1,157 @@
+package weblib
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Store is a cache that maps keys of type K to values of type V, regardless of where the values are stored.
+//
+// TypedCache is the in memory implementation. CodecStore adapts any ByteStore, such as DiskStore or a Redis client,
+// into a Store. Cache does not implement Store, as its Get returns only the value, but Cache.Store returns it as one.
+type Store[K comparable, V any] interface {
+	// Get retrieves the value with key k and true if it exists and is not expired, otherwise the zero value of V and
+	// false.
+	Get(k K) (V, bool)
+
+	// Put adds the value v with key k using the default ttl of the store.
+	Put(k K, v V)
+
+	// PutWithTTL adds the value v with key k that expires after the given ttl.
+	PutWithTTL(k K, v V, ttl time.Duration)
+
+	// Delete removes the value with key k if it exists.
+	Delete(k K)
+
+	// Close releases the resources held by the store.
+	Close()
+}
+
+var (
+	_ Store[string, any] = (*TypedCache[string, any])(nil)
+	_ Store[string, any] = (*CodecStore[any])(nil)
+)
+
+// Cache.Store must return a Store, checked without calling it during initialisation.
+var _ = func(c *Cache) Store[string, any] { return c.Store() }
+
+// Codec encodes and decodes values for stores that hold them as bytes.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// GobCodec is a Codec that uses encoding/gob. Concrete types stored behind interfaces must be registered with
+// gob.Register.
+type GobCodec struct{}
+
+// Marshal returns the gob encoding of v.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the gob encoded data into v.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec is a Codec that uses encoding/json.
+type JSONCodec struct{}
+
+// Marshal returns the JSON encoding of v.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes the JSON encoded data into v.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// ByteStore is a store of raw bytes under string keys with a ttl per entry. It is the interface to implement to use a
+// shared store, such as Redis or Memcached, as a Store through CodecStore.
+//
+// A ttl of zero or less means the entry does not expire. Get must report a missing or expired key as false with a nil
+// error.
+type ByteStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// CodecStore is a Store that encodes values of type V with a Codec and holds them in a ByteStore.
+//
+// Errors from the ByteStore or Codec are logged, and a failed Get is treated as a miss.
+type CodecStore[V any] struct {
+	store ByteStore
+	codec Codec
+	ttl   time.Duration
+}
+
+// NewCodecStore returns a Store that holds values of type V in the given ByteStore, encoded by the given codec.
+// The default expiry of an entry is determined by the ttl.
+func NewCodecStore[V any](store ByteStore, codec Codec, ttl time.Duration) *CodecStore[V] {
+	return &CodecStore[V]{store: store, codec: codec, ttl: ttl}
+}
+
+// Get retrieves the value v with key k and true if it exists and is not expired, otherwise the zero value of V and
+// false.
+func (s *CodecStore[V]) Get(k string) (v V, ok bool) {
+	data, ok, err := s.store.Get(context.Background(), k)
+	if err != nil {
+		log.Printf("cache store get %q failed: %v", k, err)
+		return v, false
+	} else if !ok {
+		return v, false
+	}
+
+	if err := s.codec.Unmarshal(data, &v); err != nil {
+		log.Printf("cache store decode %q failed: %v", k, err)
+		var zero V
+		return zero, false
+	}
+
+	return v, true
+}
+
+// Put adds the value v with key k using the default ttl of the store.
+func (s *CodecStore[V]) Put(k string, v V) {
+	s.PutWithTTL(k, v, s.ttl)
+}
+
+// PutWithTTL adds the value v with key k that expires after the given ttl.
+func (s *CodecStore[V]) PutWithTTL(k string, v V, ttl time.Duration) {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		log.Printf("cache store encode %q failed: %v", k, err)
+		return
+	}
+
+	if err := s.store.Set(context.Background(), k, data, ttl); err != nil {
+		log.Printf("cache store set %q failed: %v", k, err)
+	}
+}
+
+// Delete removes the value with key k if it exists.
+func (s *CodecStore[V]) Delete(k string) {
+	if err := s.store.Delete(context.Background(), k); err != nil {
+		log.Printf("cache store delete %q failed: %v", k, err)
+	}
+}
+
+// Close closes the underlying ByteStore.
+func (s *CodecStore[V]) Close() {
+	if err := s.store.Close(); err != nil {
+		log.Printf("cache store close failed: %v", err)
+	}
+}
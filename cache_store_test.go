@@ -0,0 +1,104 @@
+package weblib
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapByteStore is a minimal ByteStore, standing in for an adapter to a shared store such as Redis.
+type mapByteStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *mapByteStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[key]
+	return v, ok, nil
+}
+
+func (s *mapByteStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *mapByteStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func (s *mapByteStore) Close() error {
+	return nil
+}
+
+type storeTestValue struct {
+	Name  string
+	Count int
+}
+
+func TestStore_Implementations(t *testing.T) {
+	disk, err := NewDiskCache[storeTestValue](t.TempDir(), time.Minute, time.Minute, GobCodec{})
+	if err != nil {
+		t.Fatalf("failed to create disk cache: %v", err)
+	}
+
+	stores := map[string]Store[string, storeTestValue]{
+		"memory":      NewTypedCache[string, storeTestValue](time.Minute, time.Minute),
+		"disk":        disk,
+		"codec store": NewCodecStore[storeTestValue](&mapByteStore{data: map[string][]byte{}}, JSONCodec{}, time.Minute),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			defer store.Close()
+
+			want := storeTestValue{Name: "value", Count: 3}
+			store.Put("key", want)
+
+			if got, ok := store.Get("key"); !ok || got != want {
+				t.Errorf("expected %v and true, got %v and %v", want, got, ok)
+			}
+
+			store.Delete("key")
+			if _, ok := store.Get("key"); ok {
+				t.Error("expected key to be deleted")
+			}
+
+			if _, ok := store.Get("missing"); ok {
+				t.Error("expected missing key to not exist")
+			}
+		})
+	}
+}
+
+func TestCache_Store(t *testing.T) {
+	cache := NewCache(time.Minute, time.Minute)
+	defer cache.Close()
+
+	store := cache.Store()
+	store.Put("key", "value")
+
+	if got := cache.Get("key"); got != "value" {
+		t.Errorf("expected the cache to share entries with the store, got %v", got)
+	}
+
+	if got, ok := store.Get("key"); !ok || got != "value" {
+		t.Errorf("expected value and true, got %v and %v", got, ok)
+	}
+}
+
+func TestCodecStore_DecodeError(t *testing.T) {
+	bs := &mapByteStore{data: map[string][]byte{"key": []byte("not json")}}
+	store := NewCodecStore[storeTestValue](bs, JSONCodec{}, time.Minute)
+
+	if v, ok := store.Get("key"); ok || v != (storeTestValue{}) {
+		t.Errorf("expected undecodable value to be a miss, got %v and %v", v, ok)
+	}
+}
@@ -0,0 +1,424 @@
+package weblib
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressWriter is a writer that compresses the bytes written to it into an underlying writer.
+type CompressWriter interface {
+	io.WriteCloser
+
+	// Flush writes any pending compressed data to the underlying writer.
+	Flush() error
+
+	// Reset discards the state of the writer and makes it write to w, so that it can be reused.
+	Reset(w io.Writer)
+}
+
+// Encoder compresses responses with a content coding.
+type Encoder struct {
+	// Encoding is the content coding token, such as "gzip", matched against the Accept-Encoding header of the request
+	// and sent in the Content-Encoding header of the response.
+	Encoding string
+
+	// NewWriter returns a writer that compresses into w. Writers are pooled by Compress and reset for each response, so
+	// NewWriter is only called when no pooled writer is free.
+	NewWriter func(w io.Writer) CompressWriter
+}
+
+// CompressOptions configures the Compress middleware.
+type CompressOptions struct {
+	// MinSize is the size in bytes a response body must reach before it is compressed. Smaller responses are sent
+	// uncompressed, as compressing them costs more than it saves. If zero, every response with a body is compressed.
+	MinSize int
+
+	// ContentTypes are the media types of responses that are compressed. An entry ending in "/*", such as "text/*",
+	// matches every subtype. If empty, DefaultCompressibleTypes is used.
+	ContentTypes []string
+
+	// Encoders are the encoders responses may be compressed with, in order of preference when the request accepts
+	// several with the same quality value. If empty, DefaultEncoders is used.
+	Encoders []Encoder
+}
+
+// DefaultCompressibleTypes are the media types of responses compressed by default. Formats that are already
+// compressed, such as most image, audio, video, and archive formats, are excluded.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/ld+json",
+	"application/manifest+json",
+	"application/wasm",
+	"application/xhtml+xml",
+	"application/xml",
+	"image/svg+xml",
+	"image/x-icon",
+	"font/otf",
+	"font/ttf",
+}
+
+// DefaultEncoders are the encoders used by Compress by default, in order of preference.
+var DefaultEncoders = []Encoder{
+	BrotliEncoder(brotli.DefaultCompression),
+	ZstdEncoder(3),
+	GzipEncoder(gzip.DefaultCompression),
+	DeflateEncoder(zlib.DefaultCompression),
+}
+
+// BrotliEncoder returns an Encoder for the br content coding at the given level, from 0 for the fastest to 11 for the
+// best compression.
+func BrotliEncoder(level int) Encoder {
+	level = min(max(level, brotli.BestSpeed), brotli.BestCompression)
+
+	return Encoder{
+		Encoding: "br",
+		NewWriter: func(w io.Writer) CompressWriter {
+			return brotli.NewWriterLevel(w, level)
+		},
+	}
+}
+
+// ZstdEncoder returns an Encoder for the zstd content coding at the given level, from 1 for the fastest to 22 for the
+// best compression, which is mapped to the nearest level supported by the encoder. The window is limited to 8MB, the
+// largest that browsers are required to decode.
+func ZstdEncoder(level int) Encoder {
+	return Encoder{
+		Encoding: "zstd",
+		NewWriter: func(w io.Writer) CompressWriter {
+			// the options are always valid, so creating the writer cannot fail
+			zw, _ := zstd.NewWriter(w,
+				zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)),
+				zstd.WithEncoderConcurrency(1),
+				zstd.WithWindowSize(8<<20),
+			)
+			return zw
+		},
+	}
+}
+
+// GzipEncoder returns an Encoder for the gzip content coding at the given level, as accepted by gzip.NewWriterLevel.
+// An invalid level uses gzip.DefaultCompression.
+func GzipEncoder(level int) Encoder {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+
+	return Encoder{
+		Encoding: "gzip",
+		NewWriter: func(w io.Writer) CompressWriter {
+			gz, _ := gzip.NewWriterLevel(w, level)
+			return gz
+		},
+	}
+}
+
+// DeflateEncoder returns an Encoder for the deflate content coding at the given level, as accepted by
+// zlib.NewWriterLevel. An invalid level uses zlib.DefaultCompression.
+//
+// The deflate content coding is a zlib stream, as browsers expect, rather than raw deflate data.
+func DeflateEncoder(level int) Encoder {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		level = zlib.DefaultCompression
+	}
+
+	return Encoder{
+		Encoding: "deflate",
+		NewWriter: func(w io.Writer) CompressWriter {
+			zw, _ := zlib.NewWriterLevel(w, level)
+			return zw
+		},
+	}
+}
+
+// Compress returns a middleware closure that compresses a response with the encoder the request accepts with the
+// highest quality value, preferring earlier encoders in the options when several are accepted equally.
+//
+// Responses are only compressed if they have a body of at least the minimum size and a compressible content type, and
+// have not already been encoded by the handler. Range and HEAD requests are passed through uncompressed. Compressed
+// responses have their Content-Length removed and a strong ETag made weak, and all responses vary on Accept-Encoding.
+func Compress(opts CompressOptions) Middleware {
+	if len(opts.Encoders) == 0 {
+		opts.Encoders = DefaultEncoders
+	}
+
+	// compressor state is large, so writers are pooled and reset for each response rather than created anew
+	pools := make([]sync.Pool, len(opts.Encoders))
+	codings := make([]string, len(opts.Encoders))
+	for i, enc := range opts.Encoders {
+		pools[i].New = func() any { return enc.NewWriter(io.Discard) }
+		codings[i] = enc.Encoding
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			i := negotiateEncoding(r.Header.Get("Accept-Encoding"), codings)
+			if i < 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			crw := &compressRW{
+				ResponseWriter: WrapResponseWriter(w),
+				opts:           &opts,
+				enc:            &opts.Encoders[i],
+				pool:           &pools[i],
+			}
+			defer crw.close()
+
+			next.ServeHTTP(crw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the index of the content coding accepted by the Accept-Encoding header with the highest
+// quality value, preferring earlier codings when quality values are equal, or -1 if none are accepted.
+func negotiateEncoding(header string, codings []string) int {
+	accepted := acceptedEncodings(header)
+
+	best := -1
+	var bestQ float64
+	for i, coding := range codings {
+		q, ok := accepted[strings.ToLower(coding)]
+		if !ok {
+			q = accepted["*"]
+		}
+
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+
+	return best
+}
+
+// acceptedEncodings parses an Accept-Encoding header into a map of lower case codings to their quality values.
+func acceptedEncodings(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		accepted[coding] = q
+	}
+
+	return accepted
+}
+
+// compressRW is a response writer that compresses the response with an encoder once it is known to be eligible.
+type compressRW struct {
+	*ResponseWriter
+	opts     *CompressOptions
+	enc      *Encoder
+	pool     *sync.Pool
+	cw       CompressWriter
+	status   int
+	buf      []byte
+	decided  bool
+	compress bool
+	hijacked bool
+}
+
+// WriteHeader defers writing the status until it is known whether the response will be compressed.
+func (w *compressRW) WriteHeader(status int) {
+	if status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write buffers the body until it reaches the minimum size, at which point the response is compressed if it is
+// eligible.
+func (w *compressRW) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.opts.MinSize {
+			return len(b), nil
+		}
+
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.compress {
+		return w.cw.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom copies r to the response through Write.
+func (w *compressRW) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
+
+// Flush decides whether to compress the response if not already decided, then flushes the data written so far to the
+// client.
+func (w *compressRW) Flush() {
+	w.FlushError()
+}
+
+// FlushError flushes the data written so far to the client, returning an error if it fails or is not supported.
+func (w *compressRW) FlushError() error {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+
+	if w.compress {
+		if err := w.cw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return w.ResponseWriter.FlushError()
+}
+
+// Hijack lets the caller take over the connection, after which nothing more is written to the response.
+func (w *compressRW) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+// decide determines whether the response is compressed, writes the header, and writes any buffered body.
+func (w *compressRW) decide() error {
+	w.decided = true
+
+	status, _ := Default(w.status, http.StatusOK)
+	header := w.Header()
+
+	if len(w.buf) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	w.compress = len(w.buf) > 0 &&
+		len(w.buf) >= w.opts.MinSize &&
+		bodyAllowed(status) &&
+		header.Get("Content-Encoding") == "" &&
+		compressibleType(header.Get("Content-Type"), w.opts.ContentTypes)
+
+	if w.compress {
+		header.Set("Content-Encoding", w.enc.Encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		w.cw = w.pool.Get().(CompressWriter)
+		w.cw.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.compress {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+
+	return err
+}
+
+// close writes any buffered response and completes the compressed stream, returning the writer to the pool.
+func (w *compressRW) close() {
+	if w.hijacked {
+		// the stream may be incomplete, so the writer is not reused
+		return
+	}
+
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// the handler wrote nothing, so leave the implicit response to the server
+			return
+		}
+
+		w.decide()
+	}
+
+	if w.compress {
+		w.cw.Close()
+		w.pool.Put(w.cw)
+	}
+}
+
+// bodyAllowed returns true if a response with the given status may have a body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// compressibleType returns true if the media type of the Content-Type header matches one of the given types.
+func compressibleType(contentType string, types []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if len(types) == 0 {
+		types = DefaultCompressibleTypes
+	}
+
+	for _, t := range types {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package weblib
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello world ", 100)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			zr, err := zstd.NewReader(r)
+			return zr, err
+		},
+		"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "brotli preferred", acceptEncoding: "gzip, deflate, br, zstd", want: "br"},
+		{name: "highest q-value", acceptEncoding: "br;q=0.5, zstd;q=0.9, gzip;q=0.8", want: "zstd"},
+		{name: "gzip only", acceptEncoding: "gzip", want: "gzip"},
+		{name: "deflate only", acceptEncoding: "deflate", want: "deflate"},
+		{name: "wildcard", acceptEncoding: "*", want: "br"},
+		{name: "wildcard with exclusions", acceptEncoding: "*, br;q=0, zstd;q=0", want: "gzip"},
+		{name: "upper case", acceptEncoding: "GZIP", want: "gzip"},
+		{name: "unsupported", acceptEncoding: "compress, identity"},
+		{name: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			Compress(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(large))
+			})).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("expected Content-Encoding %q, got %q", tt.want, got)
+			}
+
+			body := io.Reader(rec.Body)
+			if tt.want != "" {
+				var err error
+				if body, err = decoders[tt.want](rec.Body); err != nil {
+					t.Fatalf("failed to create %s reader: %v", tt.want, err)
+				}
+			}
+
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			} else if string(b) != large {
+				t.Errorf("expected the original body, got %d bytes", len(b))
+			}
+		})
+	}
+}
+
+func TestCompress_Encoders(t *testing.T) {
+	handler := Compress(CompressOptions{
+		Encoders: []Encoder{DeflateEncoder(zlib.BestSpeed), GzipEncoder(gzip.BestSpeed)},
+	})(http.HandlerFunc(handler))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip, deflate")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "deflate" {
+		t.Errorf("expected the first configured encoder to be preferred, got %q", got)
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	handler := Compress(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected flush to be supported, got %v", err)
+		}
+	}))
+
+	for _, encoding := range []string{"br", "zstd", "gzip", "deflate"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if !rec.Flushed {
+			t.Errorf("expected %s response to be flushed", encoding)
+		}
+	}
+}
@@ -1,15 +1,300 @@
 package weblib
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/a-h/templ"
 )
 
+// Source is a source expression of a Content-Security-Policy directive, such as 'self' or https://cdn.example.com.
+type Source string
+
+// Source expressions with a special meaning. Hosts, schemes, and hashes can be used as sources by converting them to a
+// Source, such as Source("https://cdn.example.com").
+const (
+	SourceSelf           Source = "'self'"
+	SourceNone           Source = "'none'"
+	SourceUnsafeInline   Source = "'unsafe-inline'"
+	SourceUnsafeEval     Source = "'unsafe-eval'"
+	SourceUnsafeHashes   Source = "'unsafe-hashes'"
+	SourceStrictDynamic  Source = "'strict-dynamic'"
+	SourceWasmUnsafeEval Source = "'wasm-unsafe-eval'"
+	SourceData           Source = "data:"
+	SourceBlob           Source = "blob:"
+	SourceHTTPS          Source = "https:"
+
+	// SourceNonce is replaced by the nonce generated for each request by WithCSP, as 'nonce-…'. It is omitted when the
+	// policy is written without a nonce.
+	SourceNonce Source = "'nonce'"
+)
+
+// CSP is a Content-Security-Policy. Directives with no sources are omitted from the policy.
+type CSP struct {
+	DefaultSrc     []Source
+	ScriptSrc      []Source
+	StyleSrc       []Source
+	ImgSrc         []Source
+	FontSrc        []Source
+	ConnectSrc     []Source
+	MediaSrc       []Source
+	ObjectSrc      []Source
+	FrameSrc       []Source
+	ChildSrc       []Source
+	WorkerSrc      []Source
+	ManifestSrc    []Source
+	FrameAncestors []Source
+	FormAction     []Source
+	BaseURI        []Source
+
+	// UpgradeInsecureRequests instructs browsers to fetch insecure URLs of the page over HTTPS.
+	UpgradeInsecureRequests bool
+
+	// ReportURI are the URLs that browsers post violations of the policy to as legacy csp-report JSON. It is
+	// deprecated in favour of ReportTo, but is still the only reporting directive supported by some browsers.
+	ReportURI []string
+
+	// ReportTo is the name of the endpoint, set in the Reporting-Endpoints header, that browsers send violations of the
+	// policy to with the Reporting API.
+	ReportTo string
+}
+
+// CSPOptions configures the WithCSP middleware.
+type CSPOptions struct {
+	// Policy is the Content-Security-Policy set on every response. SourceNonce in its directives is replaced by the
+	// nonce generated for the request.
+	Policy CSP
+
+	// NonceSize is the size in bytes of the nonces generated for each request. If zero, 16 bytes are used.
+	NonceSize uint
+
+	// ReportOnly sets the policy in the Content-Security-Policy-Report-Only header, so that violations are reported
+	// but not blocked. This allows a policy to be measured before it is enforced.
+	ReportOnly bool
+
+	// ReportingEndpoints maps the names of Reporting API endpoints, as used by the ReportTo directive, to their URLs,
+	// which are set in the Reporting-Endpoints header.
+	ReportingEndpoints map[string]string
+}
+
+// cspDirective is a directive of a Content-Security-Policy that takes a source list.
+type cspDirective struct {
+	name    string
+	sources *[]Source
+
+	// fetch is true for fetch directives, which fall back to default-src when they have no sources
+	fetch bool
+}
+
+// sourceLists returns the directives of the policy that take source lists, in the order they are written.
+func (p *CSP) sourceLists() []cspDirective {
+	return []cspDirective{
+		{"default-src", &p.DefaultSrc, false},
+		{"script-src", &p.ScriptSrc, true},
+		{"style-src", &p.StyleSrc, true},
+		{"img-src", &p.ImgSrc, true},
+		{"font-src", &p.FontSrc, true},
+		{"connect-src", &p.ConnectSrc, true},
+		{"media-src", &p.MediaSrc, true},
+		{"object-src", &p.ObjectSrc, true},
+		{"frame-src", &p.FrameSrc, true},
+		{"child-src", &p.ChildSrc, true},
+		{"worker-src", &p.WorkerSrc, true},
+		{"manifest-src", &p.ManifestSrc, true},
+		{"frame-ancestors", &p.FrameAncestors, false},
+		{"form-action", &p.FormAction, false},
+		{"base-uri", &p.BaseURI, false},
+	}
+}
+
+// Clone returns a copy of the policy that shares no source lists with it.
+func (p CSP) Clone() CSP {
+	c := p
+	for _, d := range c.sourceLists() {
+		*d.sources = slices.Clone(*d.sources)
+	}
+	c.ReportURI = slices.Clone(p.ReportURI)
+
+	return c
+}
+
+// Merge adds the sources of every directive of the other policy to the policy, as with the Add methods. Upgrading
+// insecure requests is enabled if either policy enables it, and the reporting directives of the other policy are
+// added, replacing the report-to endpoint if it is set.
+func (p *CSP) Merge(other CSP) *CSP {
+	lists, otherLists := p.sourceLists(), other.sourceLists()
+	for i, d := range lists {
+		p.add(d.sources, d.fetch, *otherLists[i].sources)
+	}
+
+	p.UpgradeInsecureRequests = p.UpgradeInsecureRequests || other.UpgradeInsecureRequests
+	for _, uri := range other.ReportURI {
+		if !slices.Contains(p.ReportURI, uri) {
+			p.ReportURI = append(p.ReportURI, uri)
+		}
+	}
+	p.ReportTo, _ = Default(other.ReportTo, p.ReportTo)
+
+	return p
+}
+
+// add adds the sources that are not already in the directive. A fetch directive with no sources falls back to
+// default-src, so the sources of default-src are copied to it first to keep allowing them. 'none' is removed, as
+// browsers ignore it when a directive has other sources.
+func (p *CSP) add(list *[]Source, fetch bool, sources []Source) *CSP {
+	if len(sources) == 0 {
+		return p
+	}
+
+	if fetch && len(*list) == 0 {
+		*list = slices.Clone(p.DefaultSrc)
+	}
+
+	*list = appendSources(slices.DeleteFunc(*list, func(src Source) bool { return src == SourceNone }), sources...)
+	return p
+}
+
+// AddDefaultSrc adds sources to the default-src directive and returns the policy.
+func (p *CSP) AddDefaultSrc(sources ...Source) *CSP {
+	return p.add(&p.DefaultSrc, false, sources)
+}
+
+// AddScriptSrc adds sources to the script-src directive and returns the policy.
+func (p *CSP) AddScriptSrc(sources ...Source) *CSP {
+	return p.add(&p.ScriptSrc, true, sources)
+}
+
+// AddStyleSrc adds sources to the style-src directive and returns the policy.
+func (p *CSP) AddStyleSrc(sources ...Source) *CSP {
+	return p.add(&p.StyleSrc, true, sources)
+}
+
+// AddImgSrc adds sources to the img-src directive and returns the policy.
+func (p *CSP) AddImgSrc(sources ...Source) *CSP {
+	return p.add(&p.ImgSrc, true, sources)
+}
+
+// AddFontSrc adds sources to the font-src directive and returns the policy.
+func (p *CSP) AddFontSrc(sources ...Source) *CSP {
+	return p.add(&p.FontSrc, true, sources)
+}
+
+// AddConnectSrc adds sources to the connect-src directive and returns the policy.
+func (p *CSP) AddConnectSrc(sources ...Source) *CSP {
+	return p.add(&p.ConnectSrc, true, sources)
+}
+
+// AddMediaSrc adds sources to the media-src directive and returns the policy.
+func (p *CSP) AddMediaSrc(sources ...Source) *CSP {
+	return p.add(&p.MediaSrc, true, sources)
+}
+
+// AddObjectSrc adds sources to the object-src directive and returns the policy.
+func (p *CSP) AddObjectSrc(sources ...Source) *CSP {
+	return p.add(&p.ObjectSrc, true, sources)
+}
+
+// AddFrameSrc adds sources to the frame-src directive and returns the policy.
+func (p *CSP) AddFrameSrc(sources ...Source) *CSP {
+	return p.add(&p.FrameSrc, true, sources)
+}
+
+// AddChildSrc adds sources to the child-src directive and returns the policy.
+func (p *CSP) AddChildSrc(sources ...Source) *CSP {
+	return p.add(&p.ChildSrc, true, sources)
+}
+
+// AddWorkerSrc adds sources to the worker-src directive and returns the policy.
+func (p *CSP) AddWorkerSrc(sources ...Source) *CSP {
+	return p.add(&p.WorkerSrc, true, sources)
+}
+
+// AddManifestSrc adds sources to the manifest-src directive and returns the policy.
+func (p *CSP) AddManifestSrc(sources ...Source) *CSP {
+	return p.add(&p.ManifestSrc, true, sources)
+}
+
+// AddFrameAncestors adds sources to the frame-ancestors directive and returns the policy.
+func (p *CSP) AddFrameAncestors(sources ...Source) *CSP {
+	return p.add(&p.FrameAncestors, false, sources)
+}
+
+// AddFormAction adds sources to the form-action directive and returns the policy.
+func (p *CSP) AddFormAction(sources ...Source) *CSP {
+	return p.add(&p.FormAction, false, sources)
+}
+
+// AddBaseURI adds sources to the base-uri directive and returns the policy.
+func (p *CSP) AddBaseURI(sources ...Source) *CSP {
+	return p.add(&p.BaseURI, false, sources)
+}
+
+// String returns the policy as the value of a Content-Security-Policy header, omitting SourceNonce.
+func (p CSP) String() string {
+	return p.render("")
+}
+
+// render returns the policy as the value of a Content-Security-Policy header, with SourceNonce replaced by the given
+// nonce, or omitted if the nonce is empty.
+func (p *CSP) render(nonce string) string {
+	var directives []string
+	for _, d := range p.sourceLists() {
+		var b strings.Builder
+		for _, src := range *d.sources {
+			if src == SourceNonce {
+				if nonce == "" {
+					continue
+				}
+				src = Source("'nonce-" + nonce + "'")
+			}
+
+			b.WriteByte(' ')
+			b.WriteString(string(src))
+		}
+
+		if b.Len() > 0 {
+			directives = append(directives, d.name+b.String())
+		}
+	}
+
+	if p.UpgradeInsecureRequests {
+		directives = append(directives, "upgrade-insecure-requests")
+	}
+
+	if len(p.ReportURI) > 0 {
+		directives = append(directives, "report-uri "+strings.Join(p.ReportURI, " "))
+	}
+
+	if p.ReportTo != "" {
+		directives = append(directives, "report-to "+p.ReportTo)
+	}
+
+	return strings.Join(directives, "; ")
+}
+
+// cspContextKey is the context key of the policy of a request.
+type cspContextKey struct{}
+
+// CSPFromContext returns the Content-Security-Policy of the request set in the context by WithCSP, which can be
+// changed until the response header is written, such as CSPFromContext(ctx).AddConnectSrc("wss://example.com"). If
+// the context has no policy, an empty policy is returned, so changes to it have no effect.
+func CSPFromContext(ctx context.Context) *CSP {
+	if p, ok := ctx.Value(cspContextKey{}).(*CSP); ok {
+		return p
+	}
+
+	return &CSP{}
+}
+
 // GenerateNonce generates a random nonce of the given size in bytes and returns it as a hex encoded string.
 func GenerateNonce(size uint) (string, error) {
 	bytes := make([]byte, size)
@@ -24,7 +309,30 @@ func GenerateNonce(size uint) (string, error) {
 
 // WithNonce returns a middleware closure that generates random nonces of the given size in bytes and sets the
 // Content-Security-Policy response header in addition to setting it in the context for automatice usage by templ.
+//
+// The policy only has a script-src directive allowing the nonce. Use WithCSP for a complete policy.
 func WithNonce(size uint) Middleware {
+	return WithCSP(CSPOptions{
+		Policy:    CSP{ScriptSrc: []Source{SourceNonce}},
+		NonceSize: size,
+	})
+}
+
+// WithCSP returns a middleware closure that generates a random nonce for each request and sets the
+// Content-Security-Policy response header to the policy with the nonce, in addition to setting the nonce in the
+// context for automatic usage by templ.
+//
+// A copy of the policy is set in the context of each request, which handlers can change with CSPFromContext. The
+// header is set from it when the response header is written.
+func WithCSP(opts CSPOptions) Middleware {
+	size, _ := Default(opts.NonceSize, 16)
+	header := IIF(opts.ReportOnly, "Content-Security-Policy-Report-Only", "Content-Security-Policy")
+
+	var endpoints []string
+	for _, name := range slices.Sorted(maps.Keys(opts.ReportingEndpoints)) {
+		endpoints = append(endpoints, fmt.Sprintf("%s=%q", name, opts.ReportingEndpoints[name]))
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			nonce, err := GenerateNonce(size)
@@ -34,8 +342,199 @@ func WithNonce(size uint) Middleware {
 				return
 			}
 
-			w.Header().Set("Content-Security-Policy", fmt.Sprintf("script-src 'nonce-%s'", nonce))
-			next.ServeHTTP(w, r.WithContext(templ.WithNonce(r.Context(), nonce)))
+			if len(endpoints) > 0 {
+				w.Header().Set("Reporting-Endpoints", strings.Join(endpoints, ", "))
+			}
+
+			// handlers may change the policy of their request until the header is written
+			policy := opts.Policy.Clone()
+			ctx := context.WithValue(templ.WithNonce(r.Context(), nonce), cspContextKey{}, &policy)
+
+			rw := WrapResponseWriter(w)
+			rw.OnWriteHeader(func(int) {
+				w.Header().Set(header, policy.render(nonce))
+			})
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			if !rw.Written() {
+				// the handler wrote nothing, so the server writes the header after it returns
+				w.Header().Set(header, policy.render(nonce))
+			}
+		})
+	}
+}
+
+// ReferrerPolicy is a value of the Referrer-Policy header.
+type ReferrerPolicy string
+
+// The values of the Referrer-Policy header.
+const (
+	ReferrerNoReferrer                  ReferrerPolicy = "no-referrer"
+	ReferrerNoReferrerWhenDowngrade     ReferrerPolicy = "no-referrer-when-downgrade"
+	ReferrerOrigin                      ReferrerPolicy = "origin"
+	ReferrerOriginWhenCrossOrigin       ReferrerPolicy = "origin-when-cross-origin"
+	ReferrerSameOrigin                  ReferrerPolicy = "same-origin"
+	ReferrerStrictOrigin                ReferrerPolicy = "strict-origin"
+	ReferrerStrictOriginWhenCrossOrigin ReferrerPolicy = "strict-origin-when-cross-origin"
+	ReferrerUnsafeURL                   ReferrerPolicy = "unsafe-url"
+)
+
+// OpenerPolicy is a value of the Cross-Origin-Opener-Policy header.
+type OpenerPolicy string
+
+// The values of the Cross-Origin-Opener-Policy header.
+const (
+	OpenerSameOrigin            OpenerPolicy = "same-origin"
+	OpenerSameOriginAllowPopups OpenerPolicy = "same-origin-allow-popups"
+	OpenerUnsafeNone            OpenerPolicy = "unsafe-none"
+)
+
+// EmbedderPolicy is a value of the Cross-Origin-Embedder-Policy header.
+type EmbedderPolicy string
+
+// The values of the Cross-Origin-Embedder-Policy header.
+const (
+	EmbedderRequireCorp    EmbedderPolicy = "require-corp"
+	EmbedderCredentialless EmbedderPolicy = "credentialless"
+	EmbedderUnsafeNone     EmbedderPolicy = "unsafe-none"
+)
+
+// ResourcePolicy is a value of the Cross-Origin-Resource-Policy header.
+type ResourcePolicy string
+
+// The values of the Cross-Origin-Resource-Policy header.
+const (
+	ResourceSameOrigin  ResourcePolicy = "same-origin"
+	ResourceSameSite    ResourcePolicy = "same-site"
+	ResourceCrossOrigin ResourcePolicy = "cross-origin"
+)
+
+// FrameOptions is a value of the X-Frame-Options header.
+type FrameOptions string
+
+// The values of the X-Frame-Options header.
+const (
+	FrameDeny       FrameOptions = "DENY"
+	FrameSameOrigin FrameOptions = "SAMEORIGIN"
+)
+
+// PermissionsPolicy maps the features of a Permissions-Policy header to their allowlists. An allowlist entry is "self",
+// "*", or an origin such as "https://example.com", and an empty allowlist disables the feature.
+type PermissionsPolicy map[string][]string
+
+// DefaultPermissionsPolicy disables powerful features that most sites do not use.
+var DefaultPermissionsPolicy = PermissionsPolicy{
+	"accelerometer": {},
+	"camera":        {},
+	"geolocation":   {},
+	"gyroscope":     {},
+	"magnetometer":  {},
+	"microphone":    {},
+	"payment":       {},
+	"usb":           {},
+}
+
+// String returns the policy as the value of a Permissions-Policy header, with features in alphabetical order.
+func (p PermissionsPolicy) String() string {
+	var features []string
+	for _, feature := range slices.Sorted(maps.Keys(p)) {
+		allowlist := make([]string, len(p[feature]))
+		for i, origin := range p[feature] {
+			allowlist[i] = IIF(origin == "self" || origin == "*", origin, strconv.Quote(origin))
+		}
+
+		features = append(features, feature+"=("+strings.Join(allowlist, " ")+")")
+	}
+
+	return strings.Join(features, ", ")
+}
+
+// SecureHeadersOptions configures the SecureHeaders middleware. The zero value of each option uses a safe default.
+type SecureHeadersOptions struct {
+	// HSTSMaxAge is the time browsers only connect to the site over HTTPS after seeing the Strict-Transport-Security
+	// header. If zero, one year is used.
+	HSTSMaxAge time.Duration
+
+	// HSTSIncludeSubdomains applies Strict-Transport-Security to every subdomain of the site.
+	HSTSIncludeSubdomains bool
+
+	// HSTSPreload allows the site to be included in the HSTS preload lists of browsers.
+	HSTSPreload bool
+
+	// ReferrerPolicy is the value of the Referrer-Policy header. If empty, ReferrerStrictOriginWhenCrossOrigin is used.
+	ReferrerPolicy ReferrerPolicy
+
+	// OpenerPolicy is the value of the Cross-Origin-Opener-Policy header. If empty, OpenerSameOrigin is used.
+	OpenerPolicy OpenerPolicy
+
+	// EmbedderPolicy is the value of the Cross-Origin-Embedder-Policy header. If empty, the header is not set, as any
+	// policy blocks cross-origin resources that do not opt in to being embedded.
+	EmbedderPolicy EmbedderPolicy
+
+	// ResourcePolicy is the value of the Cross-Origin-Resource-Policy header. If empty, ResourceSameOrigin is used.
+	ResourcePolicy ResourcePolicy
+
+	// PermissionsPolicy is the value of the Permissions-Policy header. If nil, DefaultPermissionsPolicy is used.
+	PermissionsPolicy PermissionsPolicy
+
+	// FrameOptions is the value of the X-Frame-Options header. If empty, FrameDeny is used. The frame-ancestors
+	// directive of a Content-Security-Policy takes precedence in browsers that support it.
+	FrameOptions FrameOptions
+
+	// Disable are the names of the headers that are not set, such as Strict-Transport-Security for a site that is not
+	// served over HTTPS.
+	Disable []string
+}
+
+// SecureHeaders returns a middleware closure that sets the Strict-Transport-Security, X-Content-Type-Options,
+// Referrer-Policy, Cross-Origin-Opener-Policy, Cross-Origin-Embedder-Policy, Cross-Origin-Resource-Policy,
+// Permissions-Policy, and X-Frame-Options response headers. Handlers may override the headers it sets.
+func SecureHeaders(opts SecureHeadersOptions) Middleware {
+	maxAge, _ := Default(opts.HSTSMaxAge, 365*24*time.Hour)
+	hsts := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if opts.HSTSIncludeSubdomains {
+		hsts += "; includeSubDomains"
+	}
+	if opts.HSTSPreload {
+		hsts += "; preload"
+	}
+
+	referrer, _ := Default(opts.ReferrerPolicy, ReferrerStrictOriginWhenCrossOrigin)
+	opener, _ := Default(opts.OpenerPolicy, OpenerSameOrigin)
+	resource, _ := Default(opts.ResourcePolicy, ResourceSameOrigin)
+	frame, _ := Default(opts.FrameOptions, FrameDeny)
+
+	permissions := opts.PermissionsPolicy
+	if permissions == nil {
+		permissions = DefaultPermissionsPolicy
+	}
+
+	headers := http.Header{}
+	headers.Set("Strict-Transport-Security", hsts)
+	headers.Set("X-Content-Type-Options", "nosniff")
+	headers.Set("Referrer-Policy", string(referrer))
+	headers.Set("Cross-Origin-Opener-Policy", string(opener))
+	headers.Set("Cross-Origin-Resource-Policy", string(resource))
+	headers.Set("X-Frame-Options", string(frame))
+	if opts.EmbedderPolicy != "" {
+		headers.Set("Cross-Origin-Embedder-Policy", string(opts.EmbedderPolicy))
+	}
+	if len(permissions) > 0 {
+		headers.Set("Permissions-Policy", permissions.String())
+	}
+
+	for _, name := range opts.Disable {
+		headers.Del(name)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, values := range headers {
+				w.Header()[name] = slices.Clone(values)
+			}
+
+			next.ServeHTTP(w, r)
 		})
 	}
 }
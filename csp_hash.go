@@ -0,0 +1,167 @@
+package weblib
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// HashAlgorithm is a hash algorithm supported by Content-Security-Policy hash sources.
+type HashAlgorithm string
+
+// The hash algorithms supported by Content-Security-Policy hash sources.
+const (
+	SHA256 HashAlgorithm = "sha256"
+	SHA384 HashAlgorithm = "sha384"
+	SHA512 HashAlgorithm = "sha512"
+)
+
+// srcAttribute matches a src attribute in the opening tag of an element.
+var srcAttribute = regexp.MustCompile(`(?i)\ssrc(\s|=|/|$)`)
+
+// HashSource returns the hash source, such as 'sha256-…', that allows an inline script or style with the given
+// content. The content must be exactly the text between the opening and closing tags, including whitespace.
+func HashSource(alg HashAlgorithm, content string) Source {
+	var h hash.Hash
+	switch alg {
+	case SHA384:
+		h = sha512.New384()
+	case SHA512:
+		h = sha512.New()
+	default:
+		alg, h = SHA256, sha256.New()
+	}
+
+	h.Write([]byte(content))
+	return Source("'" + string(alg) + "-" + base64.StdEncoding.EncodeToString(h.Sum(nil)) + "'")
+}
+
+// InlineHashes returns the hash sources of the inline scripts and styles in the given HTML. Scripts with a src
+// attribute are not inline and are skipped.
+func InlineHashes(alg HashAlgorithm, html string) (scripts, styles []Source) {
+	for _, content := range inlineContents(html, "script", true) {
+		scripts = append(scripts, HashSource(alg, content))
+	}
+
+	for _, content := range inlineContents(html, "style", false) {
+		styles = append(styles, HashSource(alg, content))
+	}
+
+	return scripts, styles
+}
+
+// ComponentHashes renders the given templ components and returns the hash sources of their inline scripts and
+// styles. Components are rendered without a nonce, so it is intended for static content rendered at startup, such as
+// layouts served from a cache where a nonce for each request is not possible.
+func ComponentHashes(ctx context.Context, alg HashAlgorithm, components ...templ.Component) ([]Source, []Source, error) {
+	var buf bytes.Buffer
+	for _, c := range components {
+		if err := c.Render(ctx, &buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to render template: %w", err)
+		}
+	}
+
+	scripts, styles := InlineHashes(alg, buf.String())
+	return scripts, styles, nil
+}
+
+// AddComponentHashes renders the given templ components and adds the hash sources of their inline scripts and styles
+// to the script-src and style-src directives of the policy, as with AddScriptSrc and AddStyleSrc.
+func (p *CSP) AddComponentHashes(alg HashAlgorithm, components ...templ.Component) error {
+	scripts, styles, err := ComponentHashes(context.Background(), alg, components...)
+	if err != nil {
+		return err
+	}
+
+	p.AddScriptSrc(scripts...).AddStyleSrc(styles...)
+	return nil
+}
+
+// appendSources appends the sources that are not already in the list.
+func appendSources(list []Source, sources ...Source) []Source {
+	for _, src := range sources {
+		if !slices.Contains(list, src) {
+			list = append(list, src)
+		}
+	}
+
+	return list
+}
+
+// inlineContents returns the text content of every element with the given tag name in the HTML, skipping elements
+// with a src attribute if skipSrc is true. Script and style elements are raw text, so their content ends at the first
+// closing tag.
+func inlineContents(html, tag string, skipSrc bool) []string {
+	var contents []string
+
+	// only ASCII letters are lowered, so that indexes into lower are also indexes into html
+	b := []byte(html)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+
+	lower := string(b)
+	open, end := "<"+tag, "</"+tag
+	for i := 0; ; {
+		start := strings.Index(lower[i:], open)
+		if start < 0 {
+			return contents
+		}
+		start += i
+
+		// the tag name must end here, so that <scripts> does not match <script
+		after := start + len(open)
+		if after >= len(lower) || !strings.ContainsRune(" \t\n\r\f/>", rune(lower[after])) {
+			i = after
+			continue
+		}
+
+		gt := tagEnd(html, after)
+		if gt < 0 {
+			return contents
+		}
+
+		closing := strings.Index(lower[gt+1:], end)
+		if closing < 0 {
+			return contents
+		}
+		closing += gt + 1
+
+		if !skipSrc || !srcAttribute.MatchString(html[after:gt]) {
+			contents = append(contents, html[gt+1:closing])
+		}
+
+		i = closing + len(end)
+	}
+}
+
+// tagEnd returns the index of the > closing the opening tag whose attributes start at i, skipping quoted attribute
+// values, or -1 if the tag is not closed.
+func tagEnd(html string, i int) int {
+	var quote byte
+	for ; i < len(html); i++ {
+		switch c := html[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+
+	return -1
+}
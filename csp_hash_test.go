@@ -0,0 +1,94 @@
+package weblib
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/a-h/templ"
+)
+
+func TestHashSource(t *testing.T) {
+	tests := []struct {
+		alg  HashAlgorithm
+		want Source
+	}{
+		// the example hash from the CSP specification
+		{alg: SHA256, want: "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='"},
+		{alg: SHA384, want: "'sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO'"},
+		{alg: "md5", want: "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.alg), func(t *testing.T) {
+			if got := HashSource(tt.alg, "alert('Hello, world.');"); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInlineHashes(t *testing.T) {
+	html := `<html><head>
+		<SCRIPT type="module">import "a";</SCRIPT>
+		<script src="/app.js"></script>
+		<script data-src="x" title="a > b">console.log(1)</script>
+		<style>body { color: red }</style>
+		<scripts>not a script</scripts>
+		<p>ünïcödé</p><style media="print">p {}</style>
+	</head></html>`
+
+	scripts, styles := InlineHashes(SHA256, html)
+
+	wantScripts := []Source{
+		HashSource(SHA256, `import "a";`),
+		HashSource(SHA256, "console.log(1)"),
+	}
+	if !slices.Equal(scripts, wantScripts) {
+		t.Errorf("expected script hashes %v, got %v", wantScripts, scripts)
+	}
+
+	wantStyles := []Source{
+		HashSource(SHA256, "body { color: red }"),
+		HashSource(SHA256, "p {}"),
+	}
+	if !slices.Equal(styles, wantStyles) {
+		t.Errorf("expected style hashes %v, got %v", wantStyles, styles)
+	}
+}
+
+func TestCSP_AddComponentHashes(t *testing.T) {
+	component := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, `<script>htmx.config.defaultSwapStyle = "outerHTML"</script><style>.x{}</style>`)
+		return err
+	})
+
+	policy := CSP{ScriptSrc: []Source{SourceSelf, SourceNonce}}
+	if err := policy.AddComponentHashes(SHA384, component, component); err != nil {
+		t.Fatalf("failed to add hashes: %v", err)
+	}
+
+	want := "script-src 'self' 'nonce-abc' " + string(HashSource(SHA384, `htmx.config.defaultSwapStyle = "outerHTML"`)) +
+		"; style-src " + string(HashSource(SHA384, ".x{}"))
+	if got := policy.render("abc"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCSP_AddComponentHashes_DefaultSrc(t *testing.T) {
+	component := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, `<script>console.log(1)</script>`)
+		return err
+	})
+
+	policy := CSP{DefaultSrc: []Source{SourceSelf}}
+	if err := policy.AddComponentHashes(SHA256, component); err != nil {
+		t.Fatalf("failed to add hashes: %v", err)
+	}
+
+	want := "default-src 'self'; script-src 'self' " + string(HashSource(SHA256, "console.log(1)"))
+	if got := policy.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
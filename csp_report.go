@@ -0,0 +1,207 @@
+package weblib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+// CSPViolation is a violation of a Content-Security-Policy reported by a browser, normalised from either the legacy
+// csp-report format or the Reporting API format.
+type CSPViolation struct {
+	DocumentURL        string
+	Referrer           string
+	BlockedURL         string
+	EffectiveDirective string
+	OriginalPolicy     string
+	Disposition        string
+	SourceFile         string
+	LineNumber         int
+	ColumnNumber       int
+	StatusCode         int
+	Sample             string
+	UserAgent          string
+}
+
+// CSPReportOptions configures the CSPReportHandler.
+type CSPReportOptions struct {
+	// OnViolation is called with each violation reported. If nil, violations are logged to the Logger.
+	OnViolation func(r *http.Request, v CSPViolation)
+
+	// Logger is the logger that violations are logged to if OnViolation is nil. If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// MaxBodySize is the size in bytes of the largest report body that is read. If zero, 64KB is used.
+	MaxBodySize int64
+}
+
+// legacyCSPReport is the body of a report in the legacy csp-report format, as sent to report-uri endpoints.
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		Referrer           string `json:"referrer"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		OriginalPolicy     string `json:"original-policy"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ColumnNumber       int    `json:"column-number"`
+		StatusCode         int    `json:"status-code"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is a report in the Reporting API format, as sent to report-to endpoints.
+type reportingAPIReport struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	UserAgent string `json:"user_agent"`
+	Body      struct {
+		DocumentURL        string `json:"documentURL"`
+		Referrer           string `json:"referrer"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		OriginalPolicy     string `json:"originalPolicy"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		ColumnNumber       int    `json:"columnNumber"`
+		StatusCode         int    `json:"statusCode"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+// CSPReportHandler returns a handler for the endpoints of the report-uri and report-to directives of a
+// Content-Security-Policy. It accepts POST requests with bodies in either the legacy application/csp-report format or
+// the Reporting API application/reports+json format, and passes each violation reported to the callback or logger of
+// the options.
+func CSPReportHandler(opts CSPReportOptions) http.Handler {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	onViolation := opts.OnViolation
+	if onViolation == nil {
+		onViolation = func(r *http.Request, v CSPViolation) {
+			logger.LogAttrs(context.WithoutCancel(r.Context()), slog.LevelWarn, "csp violation",
+				slog.String("document_url", v.DocumentURL),
+				slog.String("blocked_url", v.BlockedURL),
+				slog.String("directive", v.EffectiveDirective),
+				slog.String("disposition", v.Disposition),
+				slog.String("source_file", v.SourceFile),
+				slog.Int("line", v.LineNumber),
+				slog.Int("column", v.ColumnNumber),
+				slog.String("sample", v.Sample),
+				slog.String("user_agent", v.UserAgent),
+			)
+		}
+	}
+
+	maxSize, _ := Default(opts.MaxBodySize, 64<<10)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/csp-report" && mediaType != "application/reports+json" &&
+			mediaType != "application/json" {
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+		if maxErr := new(http.MaxBytesError); errors.As(err, &maxErr) {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		violations, err := parseCSPReports(mediaType, body, r.UserAgent())
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		for _, v := range violations {
+			onViolation(r, v)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// parseCSPReports parses the violations from a report body of the given media type. Reports of the Reporting API that
+// are not CSP violations are ignored.
+func parseCSPReports(mediaType string, body []byte, userAgent string) ([]CSPViolation, error) {
+	if mediaType == "application/reports+json" {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		var violations []CSPViolation
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+
+			documentURL, _ := Default(report.Body.DocumentURL, report.URL)
+			ua, _ := Default(report.UserAgent, userAgent)
+
+			violations = append(violations, CSPViolation{
+				DocumentURL:        documentURL,
+				Referrer:           report.Body.Referrer,
+				BlockedURL:         report.Body.BlockedURL,
+				EffectiveDirective: report.Body.EffectiveDirective,
+				OriginalPolicy:     report.Body.OriginalPolicy,
+				Disposition:        report.Body.Disposition,
+				SourceFile:         report.Body.SourceFile,
+				LineNumber:         report.Body.LineNumber,
+				ColumnNumber:       report.Body.ColumnNumber,
+				StatusCode:         report.Body.StatusCode,
+				Sample:             report.Body.Sample,
+				UserAgent:          ua,
+			})
+		}
+
+		return violations, nil
+	}
+
+	var report legacyCSPReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+
+	directive, _ := Default(report.Report.EffectiveDirective, report.Report.ViolatedDirective)
+	if report.Report.DocumentURI == "" && directive == "" {
+		return nil, errors.New("missing csp-report")
+	}
+
+	return []CSPViolation{{
+		DocumentURL:        report.Report.DocumentURI,
+		Referrer:           report.Report.Referrer,
+		BlockedURL:         report.Report.BlockedURI,
+		EffectiveDirective: directive,
+		OriginalPolicy:     report.Report.OriginalPolicy,
+		Disposition:        report.Report.Disposition,
+		SourceFile:         report.Report.SourceFile,
+		LineNumber:         report.Report.LineNumber,
+		ColumnNumber:       report.Report.ColumnNumber,
+		StatusCode:         report.Report.StatusCode,
+		Sample:             report.Report.ScriptSample,
+		UserAgent:          userAgent,
+	}}, nil
+}
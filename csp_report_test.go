@@ -0,0 +1,173 @@
+package weblib
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSPReportHandler(t *testing.T) {
+	legacy := `{"csp-report": {
+		"document-uri": "https://example.com/page",
+		"referrer": "",
+		"violated-directive": "script-src-elem",
+		"original-policy": "script-src 'self'; report-uri /csp",
+		"disposition": "report",
+		"blocked-uri": "https://evil.example.com/x.js",
+		"line-number": 10,
+		"column-number": 4,
+		"source-file": "https://example.com/page",
+		"status-code": 200,
+		"script-sample": ""
+	}}`
+
+	reports := `[
+		{
+			"type": "csp-violation",
+			"age": 10,
+			"url": "https://example.com/page",
+			"user_agent": "Mozilla/5.0",
+			"body": {
+				"documentURL": "https://example.com/page",
+				"blockedURL": "inline",
+				"effectiveDirective": "style-src-attr",
+				"originalPolicy": "style-src 'self'; report-to csp",
+				"disposition": "enforce",
+				"sample": "color: red",
+				"statusCode": 200,
+				"lineNumber": 3,
+				"columnNumber": 7
+			}
+		},
+		{"type": "deprecation", "url": "https://example.com/page", "body": {}}
+	]`
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantCode    int
+		want        []CSPViolation
+	}{
+		{
+			name:        "legacy report",
+			contentType: "application/csp-report",
+			body:        legacy,
+			wantCode:    http.StatusNoContent,
+			want: []CSPViolation{{
+				DocumentURL:        "https://example.com/page",
+				BlockedURL:         "https://evil.example.com/x.js",
+				EffectiveDirective: "script-src-elem",
+				OriginalPolicy:     "script-src 'self'; report-uri /csp",
+				Disposition:        "report",
+				SourceFile:         "https://example.com/page",
+				LineNumber:         10,
+				ColumnNumber:       4,
+				StatusCode:         200,
+				UserAgent:          "test-agent",
+			}},
+		},
+		{
+			name:        "reporting api",
+			contentType: "application/reports+json",
+			body:        reports,
+			wantCode:    http.StatusNoContent,
+			want: []CSPViolation{{
+				DocumentURL:        "https://example.com/page",
+				BlockedURL:         "inline",
+				EffectiveDirective: "style-src-attr",
+				OriginalPolicy:     "style-src 'self'; report-to csp",
+				Disposition:        "enforce",
+				LineNumber:         3,
+				ColumnNumber:       7,
+				StatusCode:         200,
+				Sample:             "color: red",
+				UserAgent:          "Mozilla/5.0",
+			}},
+		},
+		{
+			name:        "wrong method",
+			method:      http.MethodGet,
+			contentType: "application/csp-report",
+			wantCode:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:        "unsupported media type",
+			contentType: "text/plain",
+			body:        legacy,
+			wantCode:    http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/csp-report",
+			body:        "{",
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "missing report",
+			contentType: "application/json",
+			body:        "{}",
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "body too large",
+			contentType: "application/csp-report",
+			body:        strings.Repeat(" ", 64<<10+1),
+			wantCode:    http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []CSPViolation
+			handler := CSPReportHandler(CSPReportOptions{
+				OnViolation: func(r *http.Request, v CSPViolation) { got = append(got, v) },
+			})
+
+			req := httptest.NewRequest(IIF(tt.method == "", http.MethodPost, tt.method), "/csp", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("User-Agent", "test-agent")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d violations, got %d", len(tt.want), len(got))
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected violation %+v, got %+v", tt.want[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCSPReportHandler_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := CSPReportHandler(CSPReportOptions{Logger: slog.New(slog.NewTextHandler(&buf, nil))})
+
+	body := `{"csp-report": {"document-uri": "https://example.com/", "effective-directive": "img-src"}}`
+	req := httptest.NewRequest(http.MethodPost, "/csp", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/csp-report")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "directive=img-src") {
+		t.Errorf("expected the violation to be logged, got %q", out)
+	}
+}
@@ -1,12 +1,14 @@
 package weblib
 
 import (
+	"context"
 	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/a-h/templ"
 )
@@ -96,3 +98,299 @@ func TestWithNonce(t *testing.T) {
 		}
 	})
 }
+
+func TestCSP_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CSP
+		want   string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "full policy",
+			policy: CSP{
+				DefaultSrc:              []Source{SourceSelf},
+				ScriptSrc:               []Source{SourceSelf, SourceNonce, "https://unpkg.com"},
+				StyleSrc:                []Source{SourceSelf, SourceNonce},
+				ImgSrc:                  []Source{SourceSelf, SourceData},
+				ConnectSrc:              []Source{SourceSelf, "wss://example.com"},
+				ObjectSrc:               []Source{SourceNone},
+				FrameAncestors:          []Source{SourceNone},
+				FormAction:              []Source{SourceSelf},
+				BaseURI:                 []Source{SourceSelf},
+				UpgradeInsecureRequests: true,
+			},
+			want: "default-src 'self'; script-src 'self' https://unpkg.com; style-src 'self'; img-src 'self' data:; " +
+				"connect-src 'self' wss://example.com; object-src 'none'; frame-ancestors 'none'; form-action 'self'; " +
+				"base-uri 'self'; upgrade-insecure-requests",
+		},
+		{
+			name:   "only nonce",
+			policy: CSP{ScriptSrc: []Source{SourceNonce}, ImgSrc: []Source{SourceSelf}},
+			want:   "img-src 'self'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithCSP(t *testing.T) {
+	var nonce string
+	handler := WithCSP(CSPOptions{
+		Policy: CSP{
+			DefaultSrc: []Source{SourceSelf},
+			ScriptSrc:  []Source{SourceSelf, SourceNonce},
+			StyleSrc:   []Source{SourceNonce},
+		},
+		NonceSize: 8,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = templ.GetNonce(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(nonce) != 16 {
+		t.Fatalf("expected a 16 character nonce in the context, got %q", nonce)
+	}
+
+	want := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "'; style-src 'nonce-" + nonce + "'"
+	if got := rec.Header().Get("Content-Security-Policy"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestWithCSP_ReportOnly(t *testing.T) {
+	handler := WithCSP(CSPOptions{
+		Policy: CSP{
+			DefaultSrc: []Source{SourceSelf},
+			ReportURI:  []string{"/csp"},
+			ReportTo:   "csp",
+		},
+		ReportOnly:         true,
+		ReportingEndpoints: map[string]string{"csp": "https://example.com/csp", "default": "/reports"},
+	})(http.HandlerFunc(handler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Header().Get("Content-Security-Policy") != "" {
+		t.Error("expected no enforced policy")
+	}
+
+	want := "default-src 'self'; report-uri /csp; report-to csp"
+	if got := rec.Header().Get("Content-Security-Policy-Report-Only"); got != want {
+		t.Errorf("expected report only policy %q, got %q", want, got)
+	}
+
+	want = `csp="https://example.com/csp", default="/reports"`
+	if got := rec.Header().Get("Reporting-Endpoints"); got != want {
+		t.Errorf("expected Reporting-Endpoints %q, got %q", want, got)
+	}
+}
+
+func TestCSP_Add(t *testing.T) {
+	policy := CSP{
+		DefaultSrc: []Source{SourceSelf},
+		ScriptSrc:  []Source{SourceSelf, SourceNonce},
+	}
+
+	policy.
+		AddScriptSrc("https://widget.example.com", SourceSelf).
+		AddConnectSrc("wss://widget.example.com").
+		AddFrameAncestors("https://partner.example.com").
+		AddImgSrc()
+
+	want := "default-src 'self'; script-src 'self' https://widget.example.com; connect-src 'self' " +
+		"wss://widget.example.com; frame-ancestors https://partner.example.com"
+	if got := policy.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCSP_Add_None(t *testing.T) {
+	policy := CSP{
+		DefaultSrc:     []Source{SourceNone},
+		FrameAncestors: []Source{SourceNone},
+	}
+
+	policy.AddConnectSrc("wss://example.com").AddFrameAncestors("https://partner.example.com")
+
+	want := "default-src 'none'; connect-src wss://example.com; frame-ancestors https://partner.example.com"
+	if got := policy.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCSP_Merge(t *testing.T) {
+	policy := CSP{DefaultSrc: []Source{SourceSelf}, ReportTo: "default"}
+	clone := policy.Clone()
+
+	policy.Merge(CSP{
+		DefaultSrc:              []Source{SourceSelf, "https://cdn.example.com"},
+		StyleSrc:                []Source{SourceUnsafeInline},
+		UpgradeInsecureRequests: true,
+		ReportURI:               []string{"/csp"},
+		ReportTo:                "csp",
+	})
+
+	want := "default-src 'self' https://cdn.example.com; style-src 'self' https://cdn.example.com 'unsafe-inline'; " +
+		"upgrade-insecure-requests; report-uri /csp; report-to csp"
+	if got := policy.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if got := clone.String(); got != "default-src 'self'; report-to default" {
+		t.Errorf("expected the clone to be unchanged, got %q", got)
+	}
+}
+
+func TestWithCSP_Override(t *testing.T) {
+	base := CSP{DefaultSrc: []Source{SourceSelf}}
+	mw := WithCSP(CSPOptions{Policy: base})
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{
+			name: "extended before write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				CSPFromContext(r.Context()).AddFrameSrc("https://widget.example.com")
+				w.Write([]byte("hello"))
+			},
+			want: "default-src 'self'; frame-src 'self' https://widget.example.com",
+		},
+		{
+			name: "ignored after write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				CSPFromContext(r.Context()).AddFrameSrc("https://widget.example.com")
+			},
+			want: "default-src 'self'",
+		},
+		{
+			name: "nothing written",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				CSPFromContext(r.Context()).AddImgSrc(SourceData)
+			},
+			want: "default-src 'self'; img-src 'self' data:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mw(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := rec.Header().Get("Content-Security-Policy"); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := base.String(); got != "default-src 'self'" {
+		t.Errorf("expected the configured policy to be unchanged, got %q", got)
+	}
+
+	if CSPFromContext(context.Background()).AddConnectSrc(SourceSelf) == nil {
+		t.Error("expected a policy without the middleware")
+	}
+}
+
+func TestSecureHeaders(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SecureHeadersOptions
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"Strict-Transport-Security":    "max-age=31536000",
+				"X-Content-Type-Options":       "nosniff",
+				"Referrer-Policy":              "strict-origin-when-cross-origin",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "",
+				"Cross-Origin-Resource-Policy": "same-origin",
+				"X-Frame-Options":              "DENY",
+				"Permissions-Policy": "accelerometer=(), camera=(), geolocation=(), gyroscope=(), magnetometer=(), " +
+					"microphone=(), payment=(), usb=()",
+			},
+		},
+		{
+			name: "configured",
+			opts: SecureHeadersOptions{
+				HSTSMaxAge:            2 * time.Hour,
+				HSTSIncludeSubdomains: true,
+				HSTSPreload:           true,
+				ReferrerPolicy:        ReferrerNoReferrer,
+				OpenerPolicy:          OpenerSameOriginAllowPopups,
+				EmbedderPolicy:        EmbedderCredentialless,
+				ResourcePolicy:        ResourceCrossOrigin,
+				PermissionsPolicy: PermissionsPolicy{
+					"geolocation": {"self", "https://maps.example.com"},
+					"fullscreen":  {"*"},
+				},
+				FrameOptions: FrameSameOrigin,
+			},
+			want: map[string]string{
+				"Strict-Transport-Security":    "max-age=7200; includeSubDomains; preload",
+				"Referrer-Policy":              "no-referrer",
+				"Cross-Origin-Opener-Policy":   "same-origin-allow-popups",
+				"Cross-Origin-Embedder-Policy": "credentialless",
+				"Cross-Origin-Resource-Policy": "cross-origin",
+				"X-Frame-Options":              "SAMEORIGIN",
+				"Permissions-Policy":           `fullscreen=(*), geolocation=(self "https://maps.example.com")`,
+			},
+		},
+		{
+			name: "disabled",
+			opts: SecureHeadersOptions{
+				PermissionsPolicy: PermissionsPolicy{},
+				Disable:           []string{"strict-transport-security", "X-Frame-Options"},
+			},
+			want: map[string]string{
+				"Strict-Transport-Security": "",
+				"X-Frame-Options":           "",
+				"Permissions-Policy":        "",
+				"X-Content-Type-Options":    "nosniff",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			SecureHeaders(tt.opts)(http.HandlerFunc(handler)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for name, want := range tt.want {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("expected %s %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSecureHeaders_Override(t *testing.T) {
+	handler := SecureHeaders(SecureHeadersOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("expected the handler to override X-Frame-Options, got %q", got)
+	}
+}
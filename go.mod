@@ -2,4 +2,8 @@ module github.com/dimmerz92/weblib
 
 go 1.24.1
 
-require github.com/a-h/templ v0.3.857
+require (
+	github.com/a-h/templ v0.3.857
+	github.com/andybalholm/brotli v1.2.0
+	github.com/klauspost/compress v1.18.0
+)
@@ -0,0 +1,143 @@
+package weblib
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// LoggerOptions configures the RequestLogger middleware.
+type LoggerOptions struct {
+	// Logger is the logger that requests are logged to. If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// StatusLevels maps a status class, such as 4 for 4xx statuses, to the level requests with a status in that class
+	// are logged at. Classes not in the map use the default levels of Error for 5xx, Warn for 4xx, and Info otherwise.
+	StatusLevels map[int]slog.Level
+
+	// SampleRate returns the fraction, from 0 to 1, of requests to the route of the given request that are logged.
+	// Requests with a 5xx status are always logged. If nil, every request is logged.
+	SampleRate func(r *http.Request) float64
+
+	// RequestIDHeader is the header the request ID is read from. If the request has no ID, one is generated and set on
+	// the response under this header. If empty, X-Request-Id is used.
+	RequestIDHeader string
+
+	// TrustedProxies are the networks of proxies whose X-Forwarded-For and X-Real-Ip headers are trusted to report the
+	// remote IP of the client. If empty, the remote address of the connection is always logged.
+	TrustedProxies []netip.Prefix
+}
+
+// defaultStatusLevels are the levels requests are logged at by status class.
+var defaultStatusLevels = map[int]slog.Level{
+	5: slog.LevelError,
+	4: slog.LevelWarn,
+}
+
+// RequestLogger returns a middleware closure that logs a structured record of every request once it has been served,
+// including the status, bytes written, remote IP, user agent, request ID, HTMX headers, and latency.
+func RequestLogger(opts LoggerOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	idHeader, _ := Default(opts.RequestIDHeader, "X-Request-Id")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(idHeader)
+			if id == "" {
+				id, _ = GenerateNonce(8)
+				w.Header().Set(idHeader, id)
+			}
+
+			rw := WrapResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			latency := time.Since(start)
+			status, _ := Default(rw.Status(), http.StatusOK)
+
+			if status < 500 && opts.SampleRate != nil && rand.Float64() >= opts.SampleRate(r) {
+				return
+			}
+
+			level, ok := opts.StatusLevels[status/100]
+			if !ok {
+				level = defaultStatusLevels[status/100]
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rw.Size()),
+				slog.String("remote_ip", RemoteIP(r, opts.TrustedProxies)),
+				slog.String("user_agent", r.UserAgent()),
+				slog.String("request_id", id),
+				slog.Duration("latency", latency),
+			}
+
+			if IsHTMX(r) {
+				attrs = append(attrs, slog.Group("htmx",
+					slog.String("target", r.Header.Get("Hx-Target")),
+					slog.String("trigger", r.Header.Get("Hx-Trigger")),
+				))
+			}
+
+			logger.LogAttrs(context.WithoutCancel(r.Context()), level, "request", attrs...)
+		})
+	}
+}
+
+// RemoteIP returns the IP address of the client that made the request.
+//
+// If the connection is from one of the trusted proxies, the X-Forwarded-For header is searched from the nearest hop for
+// the first address that is not a trusted proxy, falling back to the X-Real-Ip header. Otherwise, the remote address
+// of the connection is returned.
+func RemoteIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	trusted := func(s string) bool {
+		addr, err := netip.ParseAddr(strings.TrimSpace(s))
+		if err != nil {
+			return false
+		}
+
+		for _, p := range trustedProxies {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !trusted(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop != "" && !trusted(hop) {
+			return hop
+		}
+	}
+
+	if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+		return ip
+	}
+
+	return host
+}
@@ -0,0 +1,179 @@
+package weblib
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// serveLogged serves the request with a RequestLogger writing JSON to a buffer and returns the decoded records.
+func serveLogged(t *testing.T, opts LoggerOptions, h http.HandlerFunc, req *http.Request) []map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	opts.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	RequestLogger(opts)(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to decode log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+func TestRequestLogger(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/logtest", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-Id", "abc123")
+	req.Header.Set("Hx-Request", "true")
+	req.Header.Set("Hx-Target", "#main")
+
+	records := serveLogged(t, LoggerOptions{}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not found"))
+	}, req)
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 log record, got %d", len(records))
+	}
+
+	record := records[0]
+	expected := map[string]any{
+		"level":      "WARN",
+		"method":     "GET",
+		"path":       "/logtest",
+		"status":     float64(404),
+		"bytes":      float64(9),
+		"remote_ip":  "192.0.2.1",
+		"user_agent": "test-agent",
+		"request_id": "abc123",
+	}
+
+	for k, want := range expected {
+		if record[k] != want {
+			t.Errorf("expected %s to be %v, got %v", k, want, record[k])
+		}
+	}
+
+	if htmx, ok := record["htmx"].(map[string]any); !ok || htmx["target"] != "#main" {
+		t.Errorf("expected htmx target to be logged, got %v", record["htmx"])
+	}
+
+	if _, ok := record["latency"].(float64); !ok {
+		t.Errorf("expected latency to be logged in nanoseconds, got %v", record["latency"])
+	}
+}
+
+func TestRequestLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		levels map[int]slog.Level
+		want   string
+	}{
+		{name: "2xx default", status: http.StatusOK, want: "INFO"},
+		{name: "5xx default", status: http.StatusInternalServerError, want: "ERROR"},
+		{name: "2xx override", status: http.StatusOK, levels: map[int]slog.Level{2: slog.LevelDebug}, want: "DEBUG"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := serveLogged(t, LoggerOptions{StatusLevels: tt.levels}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if len(records) != 1 || records[0]["level"] != tt.want {
+				t.Errorf("expected level %s, got %v", tt.want, records)
+			}
+		})
+	}
+}
+
+func TestRequestLogger_Sampling(t *testing.T) {
+	opts := LoggerOptions{
+		SampleRate: func(r *http.Request) float64 {
+			return IIF(r.URL.Path == "/health", 0.0, 1.0)
+		},
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		want   int
+	}{
+		{path: "/health", status: http.StatusOK, want: 0},
+		{path: "/health", status: http.StatusServiceUnavailable, want: 1},
+		{path: "/page", status: http.StatusOK, want: 1},
+	}
+
+	for _, tt := range tests {
+		records := serveLogged(t, opts, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if len(records) != tt.want {
+			t.Errorf("%s %d: expected %d records, got %d", tt.path, tt.status, tt.want, len(records))
+		}
+	}
+}
+
+func TestRequestLogger_GeneratesRequestID(t *testing.T) {
+	handler := RequestLogger(LoggerOptions{Logger: slog.New(slog.DiscardHandler)})(http.HandlerFunc(handler))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Header().Get("X-Request-Id") == "" {
+		t.Error("expected a request ID to be generated")
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:1234", want: "203.0.113.5"},
+		{name: "untrusted forwarded ignored", remoteAddr: "203.0.113.5:1234", forwarded: "198.51.100.1", want: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1234", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "spoofed leftmost hop", remoteAddr: "10.0.0.1:1234", forwarded: "1.1.1.1, 198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "real ip fallback", remoteAddr: "10.0.0.1:1234", realIP: "198.51.100.2", want: "198.51.100.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-Ip", tt.realIP)
+			}
+
+			if got := RemoteIP(req, proxies); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,18 +2,26 @@ package weblib
 
 import (
 	"compress/gzip"
-	"io"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 )
 
 type Middleware func(http.Handler) http.Handler
 
-type gzipRW struct {
-	io.Writer
-	http.ResponseWriter
+// GzipOptions configures the GzipWith middleware.
+type GzipOptions struct {
+	// MinSize is the size in bytes a response body must reach before it is compressed. Smaller responses are sent
+	// uncompressed, as compressing them costs more than it saves. If zero, every response with a body is compressed.
+	MinSize int
+
+	// ContentTypes are the media types of responses that are compressed. An entry ending in "/*", such as "text/*",
+	// matches every subtype. If empty, DefaultCompressibleTypes is used.
+	ContentTypes []string
+
+	// Level is the gzip compression level, from gzip.BestSpeed to gzip.BestCompression, or gzip.HuffmanOnly. If zero,
+	// gzip.DefaultCompression is used.
+	Level int
 }
 
 // Chain applies the given middlewares to the next handler in the given order and returns it.
@@ -39,30 +47,20 @@ func Logger(next http.Handler) http.Handler {
 	})
 }
 
-// Write provides a custom gzip capable response writer write method.
-func (w gzipRW) Write(b []byte) (int, error) {
-	if w.Header().Get("Content-Type") == "" {
-		w.Header().Set("Content-Type", http.DetectContentType(b))
-	}
-
-	return w.Writer.Write(b)
-}
-
-// Gzip applies gzip compression to a response if it is an accepted encoding.
+// Gzip applies gzip compression to a response if it is an accepted encoding. It is equivalent to GzipWith with the
+// default options, so every eligible response with a body is compressed regardless of size.
 func Gzip(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		w.Header().Set("Content-Encoding", "gzip")
-
-		gz := gzip.NewWriter(w)
-		defer gz.Close()
+	return GzipWith(GzipOptions{})(next)
+}
 
-		gzrw := gzipRW{Writer: gz, ResponseWriter: w}
+// GzipWith returns a middleware closure that applies gzip compression to a response if it is an accepted encoding. It
+// is equivalent to Compress with only the gzip encoder at the given level.
+func GzipWith(opts GzipOptions) Middleware {
+	level, _ := Default(opts.Level, gzip.DefaultCompression)
 
-		next.ServeHTTP(gzrw, r)
+	return Compress(CompressOptions{
+		MinSize:      opts.MinSize,
+		ContentTypes: opts.ContentTypes,
+		Encoders:     []Encoder{GzipEncoder(level)},
 	})
 }
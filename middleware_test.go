@@ -100,3 +100,236 @@ func TestGzipWithoutHeader(t *testing.T) {
 		t.Errorf("did not expect gzip encoding")
 	}
 }
+
+func TestGzipFlush(t *testing.T) {
+	handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected flush to be supported, got %v", err)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Error("expected response to be flushed")
+	}
+}
+
+func TestGzipWith(t *testing.T) {
+	large := strings.Repeat("hello world ", 100)
+
+	tests := []struct {
+		name           string
+		opts           GzipOptions
+		method         string
+		reqHeader      http.Header
+		handler        http.HandlerFunc
+		wantCompressed bool
+	}{
+		{
+			name:           "large text compressed",
+			opts:           GzipOptions{MinSize: 256},
+			reqHeader:      http.Header{"Accept-Encoding": {"br;q=1.0, gzip;q=0.8"}},
+			handler:        func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(large)) },
+			wantCompressed: true,
+		},
+		{
+			name:      "small text below minimum size",
+			opts:      GzipOptions{MinSize: 256},
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+			handler:   handler,
+		},
+		{
+			name:      "gzip refused by q-value",
+			reqHeader: http.Header{"Accept-Encoding": {"gzip;q=0, deflate"}},
+			handler:   handler,
+		},
+		{
+			name:           "wildcard accepted",
+			reqHeader:      http.Header{"Accept-Encoding": {"*"}},
+			handler:        handler,
+			wantCompressed: true,
+		},
+		{
+			name:      "image not in allowlist",
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				w.Write([]byte(large))
+			},
+		},
+		{
+			name:      "no content",
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+			handler:   func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) },
+		},
+		{
+			name:      "range request",
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-4"}},
+			handler:   handler,
+		},
+		{
+			name:      "head request",
+			method:    http.MethodHead,
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+			handler:   handler,
+		},
+		{
+			name:      "already encoded",
+			reqHeader: http.Header{"Accept-Encoding": {"gzip"}},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "br")
+				w.Write([]byte(large))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(IIF(tt.method == "", http.MethodGet, tt.method), "/", nil)
+			req.Header = tt.reqHeader
+
+			rec := httptest.NewRecorder()
+			GzipWith(tt.opts)(tt.handler).ServeHTTP(rec, req)
+
+			compressed := rec.Header().Get("Content-Encoding") == "gzip"
+			if compressed != tt.wantCompressed {
+				t.Errorf("expected compressed to be %v, got %v", tt.wantCompressed, compressed)
+			}
+
+			if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Error("expected Vary: Accept-Encoding")
+			}
+
+			if compressed {
+				if _, err := gzip.NewReader(rec.Body); err != nil {
+					t.Errorf("expected a valid gzip body: %v", err)
+				}
+			} else if rec.Code == http.StatusNoContent && rec.Body.Len() != 0 {
+				t.Errorf("expected no body, got %d bytes", rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestGzipWith_Headers(t *testing.T) {
+	handler := Gzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "11")
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello world"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201, got %d", rec.Code)
+	}
+
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("expected Content-Length to be removed")
+	}
+
+	if rec.Header().Get("ETag") != `W/"abc"` {
+		t.Errorf("expected weak ETag, got %q", rec.Header().Get("ETag"))
+	}
+}
+
+func TestGzipWith_Level(t *testing.T) {
+	large := strings.Repeat("hello world ", 100)
+
+	sizes := make(map[int]int)
+	for _, level := range []int{gzip.BestSpeed, gzip.BestCompression, gzip.HuffmanOnly} {
+		handler := GzipWith(GzipOptions{Level: level})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(large))
+		}))
+
+		// serve twice so that the second response reuses the pooled writer
+		for range 2 {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			sizes[level] = rec.Body.Len()
+
+			gr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("failed to create gzip reader: %v", err)
+			}
+
+			body, err := io.ReadAll(gr)
+			if err != nil {
+				t.Fatalf("failed to read gzip body: %v", err)
+			} else if string(body) != large {
+				t.Fatalf("expected the original body, got %d bytes", len(body))
+			}
+		}
+	}
+
+	if sizes[gzip.HuffmanOnly] <= sizes[gzip.BestCompression] {
+		t.Errorf("expected huffman only to compress less than best compression, got %v", sizes)
+	}
+}
+
+// unpooledGzipRW writes the response through a gzip writer.
+type unpooledGzipRW struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w unpooledGzipRW) Write(b []byte) (int, error) {
+	return w.gz.Write(b)
+}
+
+// unpooledGzip is a gzip middleware that creates a writer for every response, as a baseline for BenchmarkGzip.
+func unpooledGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		next.ServeHTTP(unpooledGzipRW{w, gz}, r)
+	})
+}
+
+func BenchmarkGzip(b *testing.B) {
+	body := []byte(strings.Repeat("<li>hello world</li>\n", 200))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+	})
+
+	middlewares := []struct {
+		name string
+		mw   Middleware
+	}{
+		{"pooled", Gzip},
+		{"unpooled", unpooledGzip},
+	}
+
+	for _, m := range middlewares {
+		handler := m.mw(next)
+
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Accept-Encoding", "gzip")
+
+				for pb.Next() {
+					handler.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+		})
+	}
+}
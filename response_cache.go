@@ -0,0 +1,268 @@
+package weblib
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachedResponse is a complete response stored by CacheResponses.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// ResponseCacheOptions configures the CacheResponses middleware.
+type ResponseCacheOptions struct {
+	// TTL is the expiry of responses that do not set a max-age or s-maxage Cache-Control directive. If zero, the
+	// default ttl of the store is used.
+	TTL time.Duration
+
+	// Vary is the names of request headers that, in addition to the method, path, and query, select between cached
+	// responses. The Hx-Request header is always included.
+	Vary []string
+
+	// MaxBodySize is the size in bytes of the largest response body that is cached. If zero, there is no limit.
+	MaxBodySize int
+}
+
+// cacheableStatuses are the statuses of responses that may be cached.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// CacheResponses returns a middleware closure that stores complete responses to GET and HEAD requests in the given
+// store and serves subsequent matching requests from it.
+//
+// Responses are keyed by method, path, query, the request headers named in the options, and whether the request is
+// HTMX, so that partial and full page renders of the same URL are kept separate. Responses that vary on other request
+// headers, such as compressed responses varying on Accept-Encoding, are also keyed by the values of those headers. The
+// Cache-Control header of the request is honoured, where no-store bypasses the cache and no-cache or max-age=0 skip
+// serving from it. Responses are not stored if they set cookies, vary on every header, or have a Cache-Control header
+// of no-store, no-cache, or private. The max-age and s-maxage Cache-Control directives of a response override the ttl
+// it is stored with.
+func CacheResponses(cache Store[string, CachedResponse], opts ResponseCacheOptions) Middleware {
+	vary := append([]string{"Hx-Request"}, opts.Vary...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+			_, noStore := reqCC["no-store"]
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || noStore {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := responseCacheKey(r, vary)
+
+			_, noCache := reqCC["no-cache"]
+			if !noCache && reqCC["max-age"] != "0" {
+				if resp, ok := lookupResponse(cache, r, key); ok {
+					writeCachedResponse(w, r, resp)
+					return
+				}
+			}
+
+			for _, h := range vary {
+				w.Header().Add("Vary", h)
+			}
+
+			rec := newResponseCacheWriter(w, opts.MaxBodySize)
+			next.ServeHTTP(rec, r)
+
+			ttl, ok := responseTTL(r, rec, opts.TTL)
+			if !ok {
+				return
+			}
+
+			status, _ := Default(rec.Status(), http.StatusOK)
+			resp := CachedResponse{Status: status, Header: rec.header, Body: rec.body.Bytes()}
+			if resp.Header == nil {
+				resp.Header = w.Header().Clone()
+			}
+
+			store := func(key string, resp CachedResponse) {
+				if ttl > 0 {
+					cache.PutWithTTL(key, resp, ttl)
+				} else {
+					cache.Put(key, resp)
+				}
+			}
+
+			// the response varies on request headers that are not in the key, such as Accept-Encoding when compressed,
+			// so it is stored under a variant key and a marker naming those headers is stored under the key
+			if extra := responseVary(resp.Header, vary); len(extra) > 0 {
+				store(key, CachedResponse{Header: http.Header{"Vary": extra}})
+				key = variantKey(key, r, extra)
+			}
+
+			store(key, resp)
+		})
+	}
+}
+
+// responseCacheKey returns the key of the response to the request, varying on the given request headers.
+func responseCacheKey(r *http.Request, vary []string) string {
+	return variantKey(r.Method+" "+r.URL.Path+"?"+r.URL.Query().Encode(), r, vary)
+}
+
+// variantKey returns the key extended with the values of the given request headers.
+func variantKey(key string, r *http.Request, vary []string) string {
+	var b strings.Builder
+	b.WriteString(key)
+
+	for _, h := range vary {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(h), ","))
+	}
+
+	return b.String()
+}
+
+// lookupResponse returns the cached response to the request with the given key and true if it exists. If the key
+// holds a variant marker, which has no status, the response is looked up under the variant key of the request.
+func lookupResponse(cache Store[string, CachedResponse], r *http.Request, key string) (CachedResponse, bool) {
+	resp, ok := cache.Get(key)
+	if !ok || resp.Status != 0 {
+		return resp, ok
+	}
+
+	return cache.Get(variantKey(key, r, resp.Header.Values("Vary")))
+}
+
+// responseVary returns the canonical names of the request headers in the Vary header of the response that are not in
+// the given list.
+func responseVary(header http.Header, vary []string) []string {
+	var extra []string
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" || slices.Contains(extra, name) ||
+				slices.ContainsFunc(vary, func(h string) bool { return strings.EqualFold(h, name) }) {
+				continue
+			}
+
+			extra = append(extra, name)
+		}
+	}
+
+	return extra
+}
+
+// responseTTL returns the ttl to store the recorded response with and true if the response may be stored, otherwise
+// false.
+func responseTTL(r *http.Request, rec *responseCacheWriter, ttl time.Duration) (time.Duration, bool) {
+	status, _ := Default(rec.Status(), http.StatusOK)
+	if rec.overflow || !cacheableStatuses[status] || r.Header.Get("Authorization") != "" {
+		return 0, false
+	}
+
+	header := rec.header
+	if header == nil {
+		header = rec.Header()
+	}
+
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") == "*" {
+		return 0, false
+	}
+
+	cc := parseCacheControl(header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := cc[directive]; ok {
+			return 0, false
+		}
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return ttl, true
+}
+
+// writeCachedResponse writes the cached response to the response writer.
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, resp CachedResponse) {
+	for k, v := range resp.Header {
+		w.Header()[k] = append([]string(nil), v...)
+	}
+
+	w.WriteHeader(resp.Status)
+	if r.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
+
+// parseCacheControl parses the directives of a Cache-Control header into a map of lower case directive names to their
+// unquoted values.
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+
+		directives[strings.ToLower(name)] = TrimQuotes(strings.TrimSpace(value))
+	}
+
+	return directives
+}
+
+// responseCacheWriter writes a response through to the underlying response writer while recording it for the cache.
+type responseCacheWriter struct {
+	*ResponseWriter
+	header   http.Header
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+// newResponseCacheWriter returns a responseCacheWriter wrapping w that records a body of up to limit bytes.
+func newResponseCacheWriter(w http.ResponseWriter, limit int) *responseCacheWriter {
+	rec := &responseCacheWriter{ResponseWriter: WrapResponseWriter(w), limit: limit}
+	rec.OnWriteHeader(func(int) {
+		rec.header = rec.Header().Clone()
+	})
+
+	return rec
+}
+
+// Write records the bytes written, up to the body size limit, before writing them.
+func (w *responseCacheWriter) Write(b []byte) (int, error) {
+	if !w.overflow {
+		if w.limit > 0 && w.body.Len()+len(b) > w.limit {
+			w.overflow = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom copies r to the response through Write so that the bytes are recorded.
+func (w *responseCacheWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
@@ -0,0 +1,215 @@
+package weblib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCountingHandler returns a handler that renders the page or partial component and counts its calls.
+func newCountingHandler(calls *atomic.Int32, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		ConditionalRender(w, r, http.StatusOK, page, partial)
+	})
+}
+
+func TestCacheResponses(t *testing.T) {
+	cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	handler := CacheResponses(cache, ResponseCacheOptions{})(newCountingHandler(&calls, nil))
+
+	serve := func(htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/page?b=2&a=1", nil)
+		if htmx {
+			req.Header.Set("Hx-Request", "true")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve(false)
+	second := serve(false)
+	htmx := serve(true)
+
+	if calls.Load() != 2 {
+		t.Errorf("expected handler to be called twice, got %d", calls.Load())
+	}
+
+	if second.Body.String() != page.content || second.Header().Get("X-Call") != first.Header().Get("X-Call") {
+		t.Errorf("expected second response to be served from cache, got %q", second.Body.String())
+	}
+
+	if htmx.Body.String() != partial.content {
+		t.Errorf("expected HTMX request to get the partial, got %q", htmx.Body.String())
+	}
+
+	if first.Header().Get("Vary") != "Hx-Request" {
+		t.Errorf("expected Vary: Hx-Request, got %q", first.Header().Get("Vary"))
+	}
+}
+
+func TestCacheResponses_NotStored(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		reqHeader http.Header
+		header    http.Header
+	}{
+		{name: "post", method: http.MethodPost},
+		{name: "request no-store", method: http.MethodGet, reqHeader: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "authorization", method: http.MethodGet, reqHeader: http.Header{"Authorization": {"Bearer x"}}},
+		{name: "set cookie", method: http.MethodGet, header: http.Header{"Set-Cookie": {"a=b"}}},
+		{name: "response no-store", method: http.MethodGet, header: http.Header{"Cache-Control": {"no-store"}}},
+		{name: "response private", method: http.MethodGet, header: http.Header{"Cache-Control": {"private, max-age=60"}}},
+		{name: "response max-age zero", method: http.MethodGet, header: http.Header{"Cache-Control": {"max-age=0"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+			defer cache.Close()
+
+			var calls atomic.Int32
+			handler := CacheResponses(cache, ResponseCacheOptions{})(newCountingHandler(&calls, tt.header))
+
+			for range 2 {
+				req := httptest.NewRequest(tt.method, "/", nil)
+				for k, v := range tt.reqHeader {
+					req.Header[k] = v
+				}
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+
+			if calls.Load() != 2 {
+				t.Errorf("expected response to not be cached, handler called %d times", calls.Load())
+			}
+		})
+	}
+}
+
+func TestCacheResponses_RequestNoCache(t *testing.T) {
+	cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	handler := CacheResponses(cache, ResponseCacheOptions{})(newCountingHandler(&calls, nil))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the response revalidated by no-cache replaces the cached response
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if calls.Load() != 2 || rec.Header().Get("X-Call") != "2" {
+		t.Errorf("expected no-cache to bypass the cache once, handler called %d times", calls.Load())
+	}
+}
+
+func TestCacheResponses_VaryAndMaxAge(t *testing.T) {
+	cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	header := http.Header{"Cache-Control": {"public, max-age=1"}}
+	handler := CacheResponses(cache, ResponseCacheOptions{Vary: []string{"Accept-Language"}})(newCountingHandler(&calls, header))
+
+	for _, lang := range []string{"en", "fr", "en"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("expected one call per language, got %d", calls.Load())
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "en")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if calls.Load() != 3 {
+		t.Errorf("expected cached response to expire after max-age, got %d calls", calls.Load())
+	}
+}
+
+func TestCacheResponses_ResponseVary(t *testing.T) {
+	cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+	defer cache.Close()
+
+	var calls atomic.Int32
+	handler := Chain(newCountingHandler(&calls, nil), Compress(CompressOptions{}), CacheResponses(cache,
+		ResponseCacheOptions{}))
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	br := serve("br")
+	plain := serve("")
+	brAgain := serve("br")
+	plainAgain := serve("")
+
+	if br.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("expected a brotli response, got %q", br.Header().Get("Content-Encoding"))
+	}
+
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.String() != page.content {
+		t.Errorf("expected an uncompressed response, got %q encoded", plain.Header().Get("Content-Encoding"))
+	}
+
+	if brAgain.Header().Get("Content-Encoding") != "br" || brAgain.Header().Get("X-Call") != "1" {
+		t.Errorf("expected the brotli response from the cache, got call %s", brAgain.Header().Get("X-Call"))
+	}
+
+	if plainAgain.Body.String() != page.content || plainAgain.Header().Get("X-Call") != "2" {
+		t.Errorf("expected the uncompressed response from the cache, got call %s", plainAgain.Header().Get("X-Call"))
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("expected the handler to be called twice, got %d", calls.Load())
+	}
+}
+
+func TestCacheResponses_ResponseVaryCookie(t *testing.T) {
+	cache := NewTypedCache[string, CachedResponse](time.Minute, time.Minute)
+	defer cache.Close()
+
+	handler := CacheResponses(cache, ResponseCacheOptions{})(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Header().Set("Vary", "Cookie")
+		w.Write([]byte(r.Header.Get("Cookie")))
+	}))
+
+	for _, cookie := range []string{"user=a", "user=b", "user=a"} {
+		req := httptest.NewRequest(http.MethodGet, "/account", nil)
+		req.Header.Set("Cookie", cookie)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Body.String() != cookie {
+			t.Errorf("expected the page of %q, got %q", cookie, rec.Body.String())
+		}
+	}
+}
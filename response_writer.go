@@ -0,0 +1,135 @@
+package weblib
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseWriter wraps an http.ResponseWriter to record the status, size, and time of the first write of a response,
+// so that middleware can inspect what a handler wrote.
+//
+// The optional http.Flusher, http.Hijacker, http.Pusher, and io.ReaderFrom interfaces are implemented by delegating to
+// the wrapped response writer, returning http.ErrNotSupported where it does not support them. Unwrap exposes the
+// wrapped response writer to http.ResponseController.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status     int
+	size       int64
+	firstWrite time.Time
+	hooks      []func(status int)
+}
+
+// WrapResponseWriter returns a ResponseWriter wrapping w.
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// Status returns the status written, or zero if the header has not been written yet.
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of bytes of the body written.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// FirstWrite returns the time the header was written, or the zero time if it has not been written yet.
+func (w *ResponseWriter) FirstWrite() time.Time {
+	return w.firstWrite
+}
+
+// Written returns true if the header has been written.
+func (w *ResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+// OnWriteHeader adds a hook that is called with the status just before the header is written, while the headers can
+// still be changed. Hooks are called in the order they were added.
+func (w *ResponseWriter) OnWriteHeader(fn func(status int)) {
+	w.hooks = append(w.hooks, fn)
+}
+
+// WriteHeader records the status and calls the hooks before writing the header. Informational 1xx statuses are written
+// without being recorded.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+
+	if status >= 200 {
+		for _, hook := range w.hooks {
+			hook(status)
+		}
+
+		w.status = status
+		w.firstWrite = time.Now()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes the header with the status 200 OK if it has not been written yet, then writes b and records its size.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom copies r to the response like Write, allowing the wrapped response writer to use sendfile where supported.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.size += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client.
+func (w *ResponseWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError sends any buffered data to the client, returning an error if it fails or is not supported.
+func (w *ResponseWriter) FlushError() error {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack lets the caller take over the connection.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Push initiates an HTTP/2 server push.
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	rw := w.ResponseWriter
+	for {
+		if p, ok := rw.(http.Pusher); ok {
+			return p.Push(target, opts)
+		}
+
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return http.ErrNotSupported
+		}
+		rw = u.Unwrap()
+	}
+}
+
+// Unwrap returns the wrapped response writer for use by http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
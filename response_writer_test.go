@@ -0,0 +1,88 @@
+package weblib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter_Records(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := WrapResponseWriter(rec)
+
+	if rw.Written() || rw.Status() != 0 || !rw.FirstWrite().IsZero() {
+		t.Error("expected nothing to be recorded before writing")
+	}
+
+	rw.Write([]byte("hello "))
+	rw.ReadFrom(strings.NewReader("world"))
+
+	if rw.Status() != http.StatusOK {
+		t.Errorf("expected implicit status 200, got %d", rw.Status())
+	}
+
+	if rw.Size() != 11 {
+		t.Errorf("expected size 11, got %d", rw.Size())
+	}
+
+	if rw.FirstWrite().IsZero() {
+		t.Error("expected first write time to be recorded")
+	}
+
+	if rec.Body.String() != "hello world" {
+		t.Errorf("expected body to be 'hello world', got %q", rec.Body.String())
+	}
+}
+
+func TestResponseWriter_WriteHeader(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := WrapResponseWriter(rec)
+
+	var hooked []int
+	rw.OnWriteHeader(func(status int) {
+		hooked = append(hooked, status)
+		rw.Header().Set("X-Hooked", "true")
+	})
+
+	rw.WriteHeader(http.StatusNotFound)
+	rw.WriteHeader(http.StatusInternalServerError)
+
+	if rw.Status() != http.StatusNotFound || rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d and %d", rw.Status(), rec.Code)
+	}
+
+	if len(hooked) != 1 || hooked[0] != http.StatusNotFound {
+		t.Errorf("expected hook to be called once with 404, got %v", hooked)
+	}
+
+	if rec.Header().Get("X-Hooked") != "true" {
+		t.Error("expected hook to be able to set headers")
+	}
+}
+
+func TestResponseWriter_OptionalInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := WrapResponseWriter(rec)
+
+	rc := http.NewResponseController(rw)
+	if err := rc.Flush(); err != nil {
+		t.Errorf("expected flush to be supported, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("expected underlying recorder to be flushed")
+	}
+
+	if _, _, err := rw.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected hijack to not be supported, got %v", err)
+	}
+
+	if err := rw.Push("/app.js", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected push to not be supported, got %v", err)
+	}
+
+	if rw.Unwrap() != rec {
+		t.Error("expected Unwrap to return the wrapped response writer")
+	}
+}
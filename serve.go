@@ -1,10 +1,40 @@
 package weblib
 
 import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
 	"net/http"
+	"path"
 	"strings"
 )
 
+// ServeOptions configures the ServeFS handler.
+type ServeOptions struct {
+	// Browsable allows directories to be listed. If false, requests for directories are not found.
+	Browsable bool
+
+	// NotFound handles requests for files that do not exist. If nil, http.NotFound is used.
+	NotFound http.Handler
+
+	// DevDir is a directory on disk that files are read from on every request in place of the file system, such as
+	// the source directory of an embedded file system, so that changes are served without rebuilding. Responses are
+	// served with a Cache-Control header of no-cache. If empty, files are read from the file system.
+	DevDir string
+}
+
+// precompressedExtensions maps the content codings of precompressed files to their file extensions, in order of
+// preference when the request accepts several with the same quality value.
+var precompressedExtensions = []struct {
+	coding string
+	ext    string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
 // NoBrowse prevents a browser from being able to browse a file server.
 func NoBrowse(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -18,12 +48,124 @@ func NoBrowse(next http.Handler) http.Handler {
 }
 
 // ServeFiles serves files from the given root. The prefix should match the route used in your handler.
+//
+// If a file has precompressed siblings with the same name plus a .br, .zst, or .gz extension, such as app.js.br next
+// to app.js, the sibling with the content coding most preferred by the Accept-Encoding header of the request is served
+// in its place, with the Content-Type of the original file.
 func ServeFiles(prefix, root string, browsable bool) http.Handler {
-	fs := http.StripPrefix(prefix, http.FileServer(http.Dir(root)))
+	return http.StripPrefix(prefix, fileServer(http.Dir(root), ServeOptions{Browsable: browsable}))
+}
+
+// ServeFS serves files from the given file system, such as an embed.FS, in the same way as ServeFiles. The prefix
+// should match the route used in your handler. Use fs.Sub to serve a subdirectory of an embedded file system.
+func ServeFS(prefix string, fsys fs.FS, opts ServeOptions) http.Handler {
+	if opts.DevDir == "" {
+		return http.StripPrefix(prefix, fileServer(http.FS(fsys), opts))
+	}
 
-	if !browsable {
-		return NoBrowse(fs)
+	files := fileServer(http.Dir(opts.DevDir), opts)
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// fileServer returns a handler that serves files and their precompressed siblings from fsys.
+func fileServer(fsys http.FileSystem, opts ServeOptions) http.Handler {
+	notFound := opts.NotFound
+	if notFound == nil {
+		notFound = http.HandlerFunc(http.NotFound)
 	}
 
-	return fs
+	files := precompressed(fsys, http.FileServer(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the root of the prefix is stripped to an empty path
+		if !opts.Browsable && (r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/")) {
+			notFound.ServeHTTP(w, r)
+			return
+		}
+
+		if opts.NotFound != nil {
+			f, err := fsys.Open(path.Clean("/" + r.URL.Path))
+			if errors.Is(err, fs.ErrNotExist) {
+				notFound.ServeHTTP(w, r)
+				return
+			} else if err == nil {
+				f.Close()
+			}
+		}
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+// precompressed returns a handler that serves the precompressed sibling of the requested file from fsys if one is
+// accepted by the request, otherwise passing the request to next.
+func precompressed(fsys http.FileSystem, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
+		}
+		name = path.Clean(name)
+
+		if strings.HasSuffix(r.URL.Path, "/") || strings.HasSuffix(name, "/index.html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		original, err := fsys.Open(name)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer original.Close()
+
+		if stat, err := original.Stat(); err != nil || stat.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var codings []string
+		var files []http.File
+		for _, pc := range precompressedExtensions {
+			f, err := fsys.Open(name + pc.ext)
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+
+			if stat, err := f.Stat(); err == nil && !stat.IsDir() {
+				codings = append(codings, pc.coding)
+				files = append(files, f)
+			}
+		}
+
+		if len(files) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		i := negotiateEncoding(r.Header.Get("Accept-Encoding"), codings)
+		if i < 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			// sniff the type from the original file, as the compressed bytes would be detected as a binary stream
+			var buf [512]byte
+			n, _ := io.ReadFull(original, buf[:])
+			contentType = http.DetectContentType(buf[:n])
+		}
+
+		stat, _ := files[i].Stat()
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", codings[i])
+		http.ServeContent(w, r, name, stat.ModTime(), files[i])
+	})
 }
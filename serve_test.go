@@ -3,7 +3,10 @@ package weblib
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 )
 
 func TestServeFiles_RootProject(t *testing.T) {
@@ -54,3 +57,209 @@ func TestServeFiles_RootProject(t *testing.T) {
 		})
 	}
 }
+
+func TestServeFiles_Precompressed(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app.js":         "console.log('hello')",
+		"app.js.br":      "brotli",
+		"app.js.gz":      "gzip",
+		"page.custom":    "<!DOCTYPE html><p>hello</p>",
+		"page.custom.gz": "gzip",
+		"style.css":      "body {}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		acceptEncoding string
+		wantBody       string
+		wantEncoding   string
+		wantType       string
+		wantVary       bool
+	}{
+		{
+			name:           "brotli preferred",
+			url:            "/static/app.js",
+			acceptEncoding: "gzip, deflate, br",
+			wantBody:       "brotli",
+			wantEncoding:   "br",
+			wantType:       "text/javascript; charset=utf-8",
+			wantVary:       true,
+		},
+		{
+			name:           "gzip by q-value",
+			url:            "/static/app.js",
+			acceptEncoding: "br;q=0.5, gzip",
+			wantBody:       "gzip",
+			wantEncoding:   "gzip",
+			wantType:       "text/javascript; charset=utf-8",
+			wantVary:       true,
+		},
+		{
+			name:     "no accepted encoding",
+			url:      "/static/app.js",
+			wantBody: "console.log('hello')",
+			wantType: "text/javascript; charset=utf-8",
+			wantVary: true,
+		},
+		{
+			name:           "type sniffed from original",
+			url:            "/static/page.custom",
+			acceptEncoding: "gzip",
+			wantBody:       "gzip",
+			wantEncoding:   "gzip",
+			wantType:       "text/html; charset=utf-8",
+			wantVary:       true,
+		},
+		{
+			name:           "no precompressed siblings",
+			url:            "/static/style.css",
+			acceptEncoding: "br, gzip",
+			wantBody:       "body {}",
+			wantType:       "text/css; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			ServeFiles("/static/", root, false).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("expected Content-Type %q, got %q", tt.wantType, got)
+			}
+
+			if vary := rec.Header().Get("Vary") == "Accept-Encoding"; vary != tt.wantVary {
+				t.Errorf("expected Vary: Accept-Encoding to be %v", tt.wantVary)
+			}
+		})
+	}
+}
+
+func TestServeFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("console.log('hello')")},
+		"app.js.gz": {Data: []byte("gzip")},
+		"css/a.css": {Data: []byte("body {}")},
+	}
+
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("custom not found"))
+	})
+
+	tests := []struct {
+		name           string
+		opts           ServeOptions
+		url            string
+		acceptEncoding string
+		wantCode       int
+		wantBody       string
+	}{
+		{
+			name:     "file",
+			url:      "/static/css/a.css",
+			wantCode: http.StatusOK,
+			wantBody: "body {}",
+		},
+		{
+			name:           "precompressed",
+			url:            "/static/app.js",
+			acceptEncoding: "gzip",
+			wantCode:       http.StatusOK,
+			wantBody:       "gzip",
+		},
+		{
+			name:     "directory blocked",
+			url:      "/static/css/",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "directory browsable",
+			opts:     ServeOptions{Browsable: true},
+			url:      "/static/css/",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "custom not found",
+			opts:     ServeOptions{NotFound: notFound},
+			url:      "/static/missing.js",
+			wantCode: http.StatusNotFound,
+			wantBody: "custom not found",
+		},
+		{
+			name:     "custom not found for blocked directory",
+			opts:     ServeOptions{NotFound: notFound},
+			url:      "/static/",
+			wantCode: http.StatusNotFound,
+			wantBody: "custom not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			ServeFS("/static/", fsys, tt.opts).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeFS_DevDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.js")
+
+	handler := ServeFS("/static/", fstest.MapFS{"app.js": {Data: []byte("embedded")}}, ServeOptions{DevDir: dir})
+
+	for _, content := range []string{"first", "second"} {
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/app.js", nil))
+
+		if rec.Body.String() != content {
+			t.Errorf("expected body %q read from disk, got %q", content, rec.Body.String())
+		}
+
+		if rec.Header().Get("Cache-Control") != "no-cache" {
+			t.Errorf("expected Cache-Control no-cache, got %q", rec.Header().Get("Cache-Control"))
+		}
+	}
+}